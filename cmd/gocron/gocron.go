@@ -21,7 +21,7 @@ import (
 )
 
 var (
-	AppVersion           = "1.5"
+	AppVersion           = "1.6"
 	BuildDate, GitCommit string
 )
 
@@ -74,6 +74,9 @@ func runWeb(ctx *cli.Context) {
 	setEnvironment(ctx)
 	// 初始化应用
 	app.InitEnv(AppVersion)
+	host := parseHost(ctx)
+	port := parsePort(ctx)
+	app.Port = port
 	// 初始化模块 DB、定时任务等
 	initModule()
 	// 捕捉信号,配置热更新等
@@ -83,8 +86,6 @@ func runWeb(ctx *cli.Context) {
 	routers.Register(m)
 	// 注册中间件.
 	routers.RegisterMiddleware(m)
-	host := parseHost(ctx)
-	port := parsePort(ctx)
 	m.Run(host, port)
 }
 
@@ -97,6 +98,7 @@ func initModule() {
 	if err != nil {
 		logger.Fatal("读取应用配置失败", err)
 	}
+	app.InitInstance(config)
 	app.Setting = config
 
 	// 初始化DB
@@ -107,6 +109,8 @@ func initModule() {
 
 	// 初始化定时任务
 	service.ServiceTask.Initialize()
+	// 任务节点离线检查
+	service.ServiceHost.Start()
 }
 
 // 解析端口
@@ -3,10 +3,14 @@ package main
 
 import (
 	"flag"
+	"net"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ouqiang/gocron/internal/modules/agent"
 	"github.com/ouqiang/gocron/internal/modules/rpc/auth"
 	"github.com/ouqiang/gocron/internal/modules/rpc/server"
 	"github.com/ouqiang/gocron/internal/modules/utils"
@@ -27,6 +31,18 @@ func main() {
 	var keyFile string
 	var enableTLS bool
 	var logLevel string
+	var allowUsers string
+	var allowGroups string
+	var policyFile string
+	var maxOutput int
+	var maxRunning int
+	var maxQueue int
+	var serverURL string
+	var agentToken string
+	var agentId string
+	var advertiseAddr string
+	var labels string
+	var heartbeat int
 	flag.BoolVar(&allowRoot, "allow-root", false, "./gocron-node -allow-root")
 	flag.StringVar(&serverAddr, "s", "0.0.0.0:5921", "./gocron-node -s ip:port")
 	flag.BoolVar(&version, "v", false, "./gocron-node -v")
@@ -35,6 +51,18 @@ func main() {
 	flag.StringVar(&certFile, "cert-file", "", "./gocron-node -cert-file path")
 	flag.StringVar(&keyFile, "key-file", "", "./gocron-node -key-file path")
 	flag.StringVar(&logLevel, "log-level", "info", "-log-level error")
+	flag.StringVar(&allowUsers, "allow-users", "", "./gocron-node -allow-users www-data,postgres")
+	flag.StringVar(&allowGroups, "allow-groups", "", "./gocron-node -allow-groups www-data")
+	flag.StringVar(&policyFile, "policy-file", "", "./gocron-node -policy-file path")
+	flag.IntVar(&maxOutput, "max-output", 16384, "./gocron-node -max-output 16384 (KB)")
+	flag.IntVar(&maxRunning, "max-running", 0, "./gocron-node -max-running 4 (0 unlimited)")
+	flag.IntVar(&maxQueue, "max-queue", 100, "./gocron-node -max-queue 100")
+	flag.StringVar(&serverURL, "server-url", "", "./gocron-node -server-url http://gocron:5920")
+	flag.StringVar(&agentToken, "token", os.Getenv("GOCRON_AGENT_TOKEN"), "./gocron-node -token xxx")
+	flag.StringVar(&agentId, "agent-id", "", "./gocron-node -agent-id node-1")
+	flag.StringVar(&advertiseAddr, "advertise-addr", "", "./gocron-node -advertise-addr 192.168.1.10")
+	flag.StringVar(&labels, "labels", "", "./gocron-node -labels env=prod,zone=a")
+	flag.IntVar(&heartbeat, "heartbeat", 30, "./gocron-node -heartbeat 30 (seconds)")
 	flag.Parse()
 	level, err := log.ParseLevel(logLevel)
 	if err != nil {
@@ -72,5 +100,82 @@ func main() {
 		return
 	}
 
-	server.Start(serverAddr, enableTLS, certificate)
+	taskServer := server.Server{
+		AllowUsers:  splitList(allowUsers),
+		AllowGroups: splitList(allowGroups),
+		MaxOutput:   maxOutput * 1024,
+	}
+	if runtime.GOOS == "windows" && len(taskServer.AllowUsers) > 0 {
+		log.Fatal("-allow-users is not supported on windows")
+		return
+	}
+	if policyFile != "" {
+		taskServer.Policy, err = server.LoadPolicy(policyFile)
+		if err != nil {
+			log.Fatalf("failed to load policy file: %s", err)
+		}
+		log.Infof("load policy file: %s", policyFile)
+	}
+	if maxRunning < 0 || maxQueue < 0 {
+		log.Fatal("-max-running and -max-queue must be non-negative")
+	}
+	var limitRules []server.LimitRule
+	if taskServer.Policy != nil {
+		limitRules = taskServer.Policy.Limits
+	}
+	if maxRunning > 0 || len(limitRules) > 0 {
+		taskServer.Limiter = server.NewLimiter(maxRunning, maxQueue, limitRules)
+	}
+
+	if serverURL != "" {
+		go newAgent(serverURL, agentToken, agentId, advertiseAddr, serverAddr, labels, heartbeat).Run()
+	}
+
+	server.Start(serverAddr, enableTLS, certificate, taskServer)
+}
+
+// 向gocron注册, 节点标识默认使用主机名
+func newAgent(serverURL, token, id, advertiseAddr, serverAddr, labels string, heartbeat int) agent.Agent {
+	if token == "" {
+		log.Fatal("-token is required when -server-url is set")
+	}
+	if id == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			log.Fatalf("failed to get hostname: %s", err)
+		}
+		id = hostname
+	}
+	_, port, err := net.SplitHostPort(serverAddr)
+	if err != nil {
+		log.Fatalf("invalid listen address: %s", err)
+	}
+	portNum, _ := strconv.Atoi(port)
+	if heartbeat < 5 {
+		heartbeat = 5
+	}
+
+	return agent.Agent{
+		ServerURL: serverURL,
+		Token:     token,
+		Id:        id,
+		Addr:      advertiseAddr,
+		Port:      portNum,
+		Labels:    strings.Join(splitList(labels), ","),
+		Version:   AppVersion,
+		Interval:  time.Duration(heartbeat) * time.Second,
+	}
+}
+
+// 逗号分隔的列表
+func splitList(value string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
 }
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 集群实例心跳, 各实例定期续约, 过期未续约的实例视为已退出
+// 主节点只接管已退出实例未执行完成的任务, 实例重启前未执行完成的任务也视为已退出实例的
+
+type ClusterInstance struct {
+	Id       int    `json:"id" xorm:"int pk autoincr"`
+	Instance string `json:"instance" xorm:"varchar(128) notnull unique"`
+	ExpireAt int64  `json:"expire_at" xorm:"bigint notnull default 0"` // 过期时间戳
+	Renewed  int64  `json:"renewed" xorm:"bigint notnull default 0"`   // 最后续约时间戳
+	Started  int64  `json:"started" xorm:"bigint notnull default 0"`   // 实例启动时间戳
+}
+
+// 续约实例心跳, 记录不存在时创建
+func (ci *ClusterInstance) Renew(instance string, started time.Time, ttl time.Duration) error {
+	now := time.Now()
+	ci.Instance = instance
+	ci.ExpireAt = now.Add(ttl).Unix()
+	ci.Renewed = now.Unix()
+	ci.Started = started.Unix()
+	affected, err := Db.Table(ci).
+		Where("instance = ?", instance).
+		Update(CommonMap{
+			"expire_at": ci.ExpireAt,
+			"renewed":   ci.Renewed,
+			"started":   ci.Started,
+		})
+	if err != nil || affected > 0 {
+		return err
+	}
+	exist, err := Db.Where("instance = ?", instance).Exist(new(ClusterInstance))
+	if err != nil || exist {
+		return err
+	}
+	_, err = Db.Insert(ci)
+
+	return err
+}
+
+// 心跳未过期的实例及其启动时间
+func (ci *ClusterInstance) AliveList() (map[string]time.Time, error) {
+	list := make([]ClusterInstance, 0)
+	err := Db.Where("expire_at >= ?", time.Now().Unix()).Find(&list)
+	alive := make(map[string]time.Time)
+	for _, item := range list {
+		alive[item.Instance] = time.Unix(item.Started, 0)
+	}
+
+	return alive, err
+}
+
+// 已退出实例的记录的查询条件, alive为存活实例及其启动时间, 不能为空
+// 存活实例启动前开始的记录为实例重启前产生, 也视为已退出实例的
+func orphanCondition(alive map[string]time.Time) (string, []interface{}) {
+	placeholders := make([]string, 0, len(alive))
+	restarted := make([]string, 0, len(alive))
+	args := make([]interface{}, 0, len(alive)*3)
+	for instance := range alive {
+		placeholders = append(placeholders, "?")
+		args = append(args, instance)
+	}
+	for instance, started := range alive {
+		restarted = append(restarted, "(instance = ? AND start_time < ?)")
+		args = append(args, instance, started.Format(DefaultTimeFormat))
+	}
+	condition := fmt.Sprintf("(instance NOT IN (%s) OR %s)", strings.Join(placeholders, ", "), strings.Join(restarted, " OR "))
+
+	return condition, args
+}
@@ -1,16 +1,32 @@
 package models
 
 import (
+	"time"
+
 	"github.com/go-xorm/xorm"
 )
 
+type HostStatus int8
+
+const (
+	HostUnknown HostStatus = 0 // 未知, 手动添加且未注册的主机
+	HostOnline  HostStatus = 1 // 在线
+	HostOffline HostStatus = 2 // 离线, 超过离线时间未发送心跳
+)
+
 // 主机
 type Host struct {
-	Id        int16  `json:"id" xorm:"smallint pk autoincr"`
-	Name      string `json:"name" xorm:"varchar(64) notnull"`                // 主机名称
-	Alias     string `json:"alias" xorm:"varchar(32) notnull default '' "`   // 主机别名
-	Port      int    `json:"port" xorm:"notnull default 5921"`               // 主机端口
-	Remark    string `json:"remark" xorm:"varchar(100) notnull default '' "` // 备注
+	Id        int16      `json:"id" xorm:"smallint pk autoincr"`
+	Name      string     `json:"name" xorm:"varchar(64) notnull"`                      // 主机名称
+	Alias     string     `json:"alias" xorm:"varchar(32) notnull default '' "`         // 主机别名
+	Port      int        `json:"port" xorm:"notnull default 5921"`                     // 主机端口
+	Remark    string     `json:"remark" xorm:"varchar(100) notnull default '' "`       // 备注
+	AgentId   string     `json:"agent_id" xorm:"varchar(64) notnull default '' index"` // 自动注册的任务节点标识, 手动添加的主机为空
+	Labels    string     `json:"labels" xorm:"varchar(512) notnull default '' "`       // 标签, 逗号分隔, 如 env=prod,zone=a
+	Version   string     `json:"version" xorm:"varchar(32) notnull default '' "`       // 任务节点版本
+	Os        string     `json:"os" xorm:"varchar(32) notnull default '' "`            // 任务节点操作系统及架构, 如 linux/amd64
+	Status    HostStatus `json:"status" xorm:"tinyint notnull default 0"`              // 状态 0:未知 1:在线 2:离线
+	LastSeen  time.Time  `json:"last_seen" xorm:"datetime"`                            // 最后心跳时间
 	BaseModel `json:"-" xorm:"-"`
 	Selected  bool `json:"-" xorm:"-"`
 }
@@ -26,7 +42,7 @@ func (host *Host) Create() (insertId int16, err error) {
 }
 
 func (host *Host) UpdateBean(id int16) (int64, error) {
-	return Db.ID(id).Cols("name,alias,port,remark").Update(host)
+	return Db.ID(id).Cols("name,alias,port,remark,labels").Update(host)
 }
 
 // 更新
@@ -45,6 +61,45 @@ func (host *Host) Find(id int) error {
 	return err
 }
 
+func (host *Host) FindByAgentId(agentId string) error {
+	_, err := Db.Where("agent_id = ?", agentId).Get(host)
+
+	return err
+}
+
+func (host *Host) FindByAddr(name string, port int) error {
+	_, err := Db.Where("name = ? AND port = ?", name, port).Get(host)
+
+	return err
+}
+
+// 地址是否已被其他主机使用
+func (host *Host) AddrExists(name string, port int, id int16) (bool, error) {
+	count, err := Db.Where("name = ? AND port = ? AND id != ?", name, port, id).Count(host)
+
+	return count > 0, err
+}
+
+// 任务节点注册, 更新地址、标签等信息并标记为在线
+func (host *Host) Register(id int16) (int64, error) {
+	return Db.ID(id).Cols("agent_id,name,port,labels,version,os,status,last_seen").Update(host)
+}
+
+// 任务节点心跳, 返回更新的记录数, 为0时任务节点未注册
+func (host *Host) Heartbeat(agentId string) (int64, error) {
+	return Db.Table(host).Where("agent_id = ?", agentId).Update(CommonMap{
+		"status":    HostOnline,
+		"last_seen": time.Now().Format(DefaultTimeFormat),
+	})
+}
+
+// 最后心跳时间早于指定时间的在线主机标记为离线
+func (host *Host) MarkOffline(before time.Time) (int64, error) {
+	return Db.Table(host).Where("status = ? AND last_seen < ?", HostOnline, before.Format(DefaultTimeFormat)).Update(CommonMap{
+		"status": HostOffline,
+	})
+}
+
 func (host *Host) NameExists(name string, id int16) (bool, error) {
 	if id == 0 {
 		count, err := Db.Where("name = ?", name).Count(host)
@@ -72,6 +127,14 @@ func (host *Host) AllList() ([]Host, error) {
 	return list, err
 }
 
+// 参与标签匹配的主机, 不含离线主机
+func (host *Host) SelectableList() ([]Host, error) {
+	list := make([]Host, 0)
+	err := Db.Where("status != ?", HostOffline).Asc("id").Find(&list)
+
+	return list, err
+}
+
 func (host *Host) Total(params CommonMap) (int64, error) {
 	session := Db.NewSession()
 	host.parseWhere(session, params)
@@ -16,7 +16,7 @@ func (migration *Migration) Install(dbName string) error {
 	setting := new(Setting)
 	task := new(Task)
 	tables := []interface{}{
-		&User{}, task, &TaskLog{}, &Host{}, setting, &LoginLog{}, &TaskHost{},
+		&User{}, task, &TaskLog{}, &Host{}, setting, &LoginLog{}, &TaskHost{}, &SchedulerLease{}, &TaskChange{}, &WorkflowRun{}, &TaskLogHost{}, &ClusterInstance{},
 	}
 	for _, table := range tables {
 		exist, err := Db.IsTableExist(table)
@@ -43,13 +43,14 @@ func (migration *Migration) Upgrade(oldVersionId int) {
 		return
 	}
 
-	versionIds := []int{110, 122, 130, 140, 150}
+	versionIds := []int{110, 122, 130, 140, 150, 160}
 	upgradeFuncs := []func(*xorm.Session) error{
 		migration.upgradeFor110,
 		migration.upgradeFor122,
 		migration.upgradeFor130,
 		migration.upgradeFor140,
 		migration.upgradeFor150,
+		migration.upgradeFor160,
 	}
 
 	startIndex := -1
@@ -235,3 +236,25 @@ func (m *Migration) upgradeFor150(session *xorm.Session) error {
 
 	return nil
 }
+
+// 升级到v1.6版本
+func (m *Migration) upgradeFor160(session *xorm.Session) error {
+	logger.Info("开始升级到v1.6")
+
+	// 同步表结构, 新增字段
+	// task: interrupt_policy, misfire_policy, misfire_limit, timezone, dependency_trigger, env, work_dir, shell, run_as_user, run_as_group, cpu_limit, memory_limit, pids_limit, stop_signal, stop_grace, host_selector, dispatch_strategy, success_policy, success_threshold, script, interpreter
+	// task_log: stdout, stderr, exit_code, signal, instance, workflow_run_id, peak_memory, cpu_time, output_file, script_hash
+	// host: agent_id, labels, version, os, status, last_seen
+	// 新增表 scheduler_lease, task_change, workflow_run, task_log_host, cluster_instance
+	tables := []interface{}{
+		new(Task), new(TaskLog), new(Host), new(SchedulerLease), new(TaskChange), new(WorkflowRun), new(TaskLogHost), new(ClusterInstance),
+	}
+	err := session.Sync2(tables...)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("已升级到v1.6\n")
+
+	return nil
+}
@@ -1,7 +1,10 @@
 package models
 
 import (
+	"database/sql"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -11,6 +14,7 @@ import (
 	"github.com/go-xorm/core"
 	"github.com/go-xorm/xorm"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/ouqiang/gocron/internal/modules/app"
 	"github.com/ouqiang/gocron/internal/modules/logger"
 	"github.com/ouqiang/gocron/internal/modules/setting"
@@ -23,12 +27,14 @@ var TablePrefix = ""
 var Db *xorm.Engine
 
 const (
-	Disabled Status = 0 // 禁用
-	Failure  Status = 0 // 失败
-	Enabled  Status = 1 // 启用
-	Running  Status = 1 // 运行中
-	Finish   Status = 2 // 完成
-	Cancel   Status = 3 // 取消
+	Disabled    Status = 0 // 禁用
+	Failure     Status = 0 // 失败
+	Enabled     Status = 1 // 启用
+	Running     Status = 1 // 运行中
+	Finish      Status = 2 // 完成
+	Cancel      Status = 3 // 取消
+	Interrupted Status = 4 // 中断, 调度器重启时未执行完成
+	Queued      Status = 5 // 排队中
 )
 
 const (
@@ -73,7 +79,7 @@ func (model *BaseModel) pageLimitOffset() int {
 // 创建Db
 func CreateDb() *xorm.Engine {
 	dsn := getDbEngineDSN(app.Setting)
-	engine, err := xorm.NewEngine(app.Setting.Db.Engine, dsn)
+	engine, err := xorm.NewEngine(getDbDriverName(app.Setting), dsn)
 	if err != nil {
 		logger.Fatal("创建xorm引擎失败", err)
 	}
@@ -102,7 +108,37 @@ func CreateDb() *xorm.Engine {
 func CreateTmpDb(setting *setting.Setting) (*xorm.Engine, error) {
 	dsn := getDbEngineDSN(setting)
 
-	return xorm.NewEngine(setting.Db.Engine, dsn)
+	return xorm.NewEngine(getDbDriverName(setting), dsn)
+}
+
+// 获取数据库驱动名称
+func getDbDriverName(setting *setting.Setting) string {
+	engine := strings.ToLower(setting.Db.Engine)
+	if engine == "sqlite" {
+		return "sqlite3"
+	}
+
+	return engine
+}
+
+// SQLite驱动是否可用, 以CGO_ENABLED=0编译时go-sqlite3只包含无法连接的占位驱动
+func SqliteAvailable() bool {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return false
+	}
+	defer db.Close()
+
+	return db.Ping() == nil
+}
+
+// 获取SQLite数据库文件路径, 相对路径基于应用根目录
+func sqliteDbFile(setting *setting.Setting) string {
+	if filepath.IsAbs(setting.Db.Database) {
+		return setting.Db.Database
+	}
+
+	return filepath.Join(app.AppDir, setting.Db.Database)
 }
 
 // 获取数据库引擎DSN  mysql,sqlite,postgres
@@ -125,6 +161,14 @@ func getDbEngineDSN(setting *setting.Setting) string {
 			setting.Db.Host,
 			setting.Db.Port,
 			setting.Db.Database)
+	case "sqlite":
+		dbFile := sqliteDbFile(setting)
+		err := os.MkdirAll(filepath.Dir(dbFile), 0755)
+		if err != nil {
+			logger.Errorf("创建SQLite数据库目录失败: %s", err)
+		}
+		// WAL模式下读写不互相阻塞, 写冲突时等待锁释放
+		dsn = fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000", dbFile)
 	}
 
 	return dsn
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+)
+
+// 调度器租约, 集群模式下持有未过期租约的实例为主节点
+// 过期时间使用各实例本地时钟, 集群内实例需保持时间同步
+
+type SchedulerLease struct {
+	Id       int    `json:"id" xorm:"int pk autoincr"`
+	Name     string `json:"name" xorm:"varchar(32) notnull unique"`
+	Holder   string `json:"holder" xorm:"varchar(128) notnull default '' "` // 持有者实例标识
+	ExpireAt int64  `json:"expire_at" xorm:"bigint notnull default 0"`      // 过期时间戳
+	Renewed  int64  `json:"renewed" xorm:"bigint notnull default 0"`        // 最后续约时间戳
+}
+
+// 获取或续约租约, 租约已过期或持有者为当前实例时获取成功
+func (lease *SchedulerLease) Acquire(name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	lease.Name = name
+	lease.Holder = holder
+	lease.ExpireAt = now.Add(ttl).Unix()
+	lease.Renewed = now.Unix()
+	affected, err := Db.Table(lease).
+		Where("name = ? AND (holder = ? OR expire_at < ?)", name, holder, now.Unix()).
+		Update(CommonMap{
+			"holder":    lease.Holder,
+			"expire_at": lease.ExpireAt,
+			"renewed":   lease.Renewed,
+		})
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		return true, nil
+	}
+
+	exist, err := Db.Where("name = ?", name).Exist(new(SchedulerLease))
+	if err != nil || exist {
+		return false, err
+	}
+	// 租约记录不存在时创建, 唯一索引保证只有一个实例创建成功
+	_, err = Db.Insert(lease)
+
+	return err == nil, nil
+}
+
+// 释放租约, 其他实例可立即获取
+func (lease *SchedulerLease) Release(name, holder string) error {
+	_, err := Db.Table(lease).
+		Where("name = ? AND holder = ?", name, holder).
+		Update(CommonMap{"expire_at": 0})
+
+	return err
+}
+
+// 获取租约信息
+func (lease *SchedulerLease) Get(name string) (bool, error) {
+	return Db.Where("name = ?", name).Get(lease)
+}
+
+// 租约是否有效
+func (lease *SchedulerLease) Valid() bool {
+	return lease.Holder != "" && lease.ExpireAt >= time.Now().Unix()
+}
@@ -11,10 +11,16 @@ import (
 type TaskProtocol int8
 
 const (
-	TaskHTTP TaskProtocol = iota + 1 // HTTP协议
-	TaskRPC                          // RPC方式执行命令
+	TaskHTTP   TaskProtocol = iota + 1 // HTTP协议
+	TaskRPC                            // RPC方式执行命令
+	TaskScript                         // RPC方式执行脚本
 )
 
+// 是否在任务节点上执行
+func (p TaskProtocol) OnHost() bool {
+	return p == TaskRPC || p == TaskScript
+}
+
 type TaskLevel int8
 
 const (
@@ -29,6 +35,47 @@ const (
 	TaskDependencyStatusWeak   TaskDependencyStatus = 2 // 弱依赖
 )
 
+type TaskDependencyTrigger int8
+
+const (
+	TaskDependencyTriggerAll TaskDependencyTrigger = 1 // 所有上游任务满足依赖关系后执行
+	TaskDependencyTriggerAny TaskDependencyTrigger = 2 // 任一上游任务满足依赖关系后执行
+)
+
+type TaskInterruptPolicy int8
+
+const (
+	TaskInterruptPolicyNone  TaskInterruptPolicy = 0 // 不处理
+	TaskInterruptPolicyRerun TaskInterruptPolicy = 1 // 重新执行
+)
+
+type TaskMisfirePolicy int8
+
+const (
+	TaskMisfirePolicySkip    TaskMisfirePolicy = 0 // 跳过
+	TaskMisfirePolicyRunOnce TaskMisfirePolicy = 1 // 补执行一次
+	TaskMisfirePolicyRunAll  TaskMisfirePolicy = 2 // 补执行所有错过的调度, 不超过上限次数
+)
+
+type TaskDispatchStrategy int8
+
+const (
+	TaskDispatchAll          TaskDispatchStrategy = 0 // 所有主机执行
+	TaskDispatchRoundRobin   TaskDispatchStrategy = 1 // 轮询选择一个主机
+	TaskDispatchRandom       TaskDispatchStrategy = 2 // 随机选择一个主机
+	TaskDispatchLeastRunning TaskDispatchStrategy = 3 // 选择执行中任务最少的主机
+	TaskDispatchFailover     TaskDispatchStrategy = 4 // 按顺序选择主机, 无法连接时使用下一个主机
+)
+
+type TaskSuccessPolicy int8
+
+const (
+	TaskSuccessAll     TaskSuccessPolicy = 0 // 所有主机执行成功
+	TaskSuccessAny     TaskSuccessPolicy = 1 // 至少一个主机执行成功
+	TaskSuccessCount   TaskSuccessPolicy = 2 // 至少N个主机执行成功
+	TaskSuccessPercent TaskSuccessPolicy = 3 // 执行成功的主机比例不低于N%
+)
+
 type TaskHTTPMethod int8
 
 const (
@@ -38,31 +85,54 @@ const (
 
 // 任务
 type Task struct {
-	Id               int                  `json:"id" xorm:"int pk autoincr"`
-	Name             string               `json:"name" xorm:"varchar(32) notnull"`                            // 任务名称
-	Level            TaskLevel            `json:"level" xorm:"tinyint notnull index default 1"`               // 任务等级 1: 主任务 2: 依赖任务
-	DependencyTaskId string               `json:"dependency_task_id" xorm:"varchar(64) notnull default ''"`   // 依赖任务ID,多个ID逗号分隔
-	DependencyStatus TaskDependencyStatus `json:"dependency_status" xorm:"tinyint notnull default 1"`         // 依赖关系 1:强依赖 主任务执行成功, 依赖任务才会被执行 2:弱依赖
-	Spec             string               `json:"spec" xorm:"varchar(64) notnull"`                            // crontab
-	Protocol         TaskProtocol         `json:"protocol" xorm:"tinyint notnull index"`                      // 协议 1:http 2:系统命令
-	Command          string               `json:"command" xorm:"varchar(256) notnull"`                        // URL地址或shell命令
-	HttpMethod       TaskHTTPMethod       `json:"http_method" xorm:"tinyint notnull default 1"`               // http请求方法
-	Timeout          int                  `json:"timeout" xorm:"mediumint notnull default 0"`                 // 任务执行超时时间(单位秒),0不限制
-	Multi            int8                 `json:"multi" xorm:"tinyint notnull default 1"`                     // 是否允许多实例运行
-	RetryTimes       int8                 `json:"retry_times" xorm:"tinyint notnull default 0"`               // 重试次数
-	RetryInterval    int16                `json:"retry_interval" xorm:"smallint notnull default 0"`           // 重试间隔时间
-	NotifyStatus     int8                 `json:"notify_status" xorm:"tinyint notnull default 1"`             // 任务执行结束是否通知 0: 不通知 1: 失败通知 2: 执行结束通知 3: 任务执行结果关键字匹配通知
-	NotifyType       int8                 `json:"notify_type" xorm:"tinyint notnull default 0"`               // 通知类型 1: 邮件 2: slack 3: webhook
-	NotifyReceiverId string               `json:"notify_receiver_id" xorm:"varchar(256) notnull default '' "` // 通知接受者ID, setting表主键ID，多个ID逗号分隔
-	NotifyKeyword    string               `json:"notify_keyword" xorm:"varchar(128) notnull default '' "`
-	Tag              string               `json:"tag" xorm:"varchar(32) notnull default ''"`
-	Remark           string               `json:"remark" xorm:"varchar(100) notnull default ''"` // 备注
-	Status           Status               `json:"status" xorm:"tinyint notnull index default 0"` // 状态 1:正常 0:停止
-	Created          time.Time            `json:"created" xorm:"datetime notnull created"`       // 创建时间
-	Deleted          time.Time            `json:"deleted" xorm:"datetime deleted"`               // 删除时间
-	BaseModel        `json:"-" xorm:"-"`
-	Hosts            []TaskHostDetail `json:"hosts" xorm:"-"`
-	NextRunTime      time.Time        `json:"next_run_time" xorm:"-"`
+	Id                int                   `json:"id" xorm:"int pk autoincr"`
+	Name              string                `json:"name" xorm:"varchar(32) notnull"`                            // 任务名称
+	Level             TaskLevel             `json:"level" xorm:"tinyint notnull index default 1"`               // 任务等级 1: 主任务 2: 依赖任务
+	DependencyTaskId  string                `json:"dependency_task_id" xorm:"varchar(64) notnull default ''"`   // 依赖任务ID,多个ID逗号分隔
+	DependencyStatus  TaskDependencyStatus  `json:"dependency_status" xorm:"tinyint notnull default 1"`         // 依赖关系 1:强依赖 主任务执行成功, 依赖任务才会被执行 2:弱依赖
+	DependencyTrigger TaskDependencyTrigger `json:"dependency_trigger" xorm:"tinyint notnull default 1"`        // 有多个上游任务时的执行条件 1:所有上游任务 2:任一上游任务
+	Spec              string                `json:"spec" xorm:"varchar(64) notnull"`                            // crontab
+	Timezone          string                `json:"timezone" xorm:"varchar(64) notnull default ''"`             // crontab表达式使用的时区, 为空时使用服务器时区
+	Protocol          TaskProtocol          `json:"protocol" xorm:"tinyint notnull index"`                      // 协议 1:http 2:系统命令 3:脚本
+	Command           string                `json:"command" xorm:"varchar(256) notnull"`                        // URL地址或shell命令, 脚本任务为脚本参数
+	Script            string                `json:"script" xorm:"mediumtext"`                                   // 脚本任务的脚本内容
+	Interpreter       string                `json:"interpreter" xorm:"varchar(64) notnull default ''"`          // 脚本任务解释器, 如 bash、python3、perl
+	Env               string                `json:"env" xorm:"text"`                                            // shell任务环境变量, 每行一个 KEY=VALUE
+	WorkDir           string                `json:"work_dir" xorm:"varchar(256) notnull default ''"`            // shell任务工作目录, 为空时使用任务节点的当前目录
+	Shell             string                `json:"shell" xorm:"varchar(128) notnull default ''"`               // shell任务解释器, 为空时使用默认shell
+	RunAsUser         string                `json:"run_as_user" xorm:"varchar(32) notnull default ''"`          // shell任务执行用户, 为空时使用任务节点的运行用户
+	RunAsGroup        string                `json:"run_as_group" xorm:"varchar(32) notnull default ''"`         // shell任务执行用户组, 为空时使用执行用户的主组
+	CpuLimit          int                   `json:"cpu_limit" xorm:"int notnull default 0"`                     // shell任务CPU使用率上限(%), 100为1个核, 0不限制
+	MemoryLimit       int                   `json:"memory_limit" xorm:"int notnull default 0"`                  // shell任务内存上限(MB), 0不限制
+	PidsLimit         int                   `json:"pids_limit" xorm:"int notnull default 0"`                    // shell任务最大进程数, 0不限制
+	StopSignal        string                `json:"stop_signal" xorm:"varchar(16) notnull default ''"`          // shell任务超时或手动停止时发送的信号, 为空时使用SIGTERM
+	StopGrace         int                   `json:"stop_grace" xorm:"int notnull default 0"`                    // 发送停止信号后等待进程退出的时间(秒), 之后发送SIGKILL, 0直接发送SIGKILL
+	HostSelector      string                `json:"host_selector" xorm:"varchar(256) notnull default ''"`       // 主机标签选择器, 如 role=worker,env=prod, 执行时匹配标签的主机与任务绑定的主机一起执行
+	DispatchStrategy  TaskDispatchStrategy  `json:"dispatch_strategy" xorm:"tinyint notnull default 0"`         // 多个主机时的执行方式 0: 所有主机 1: 轮询 2: 随机 3: 最少执行中任务 4: 故障转移
+	SuccessPolicy     TaskSuccessPolicy     `json:"success_policy" xorm:"tinyint notnull default 0"`            // 多个主机执行时的成功条件 0: 所有主机成功 1: 任一主机成功 2: 至少N个主机成功 3: 成功比例不低于N%
+	SuccessThreshold  int16                 `json:"success_threshold" xorm:"smallint notnull default 0"`        // 成功条件中的N
+	HttpMethod        TaskHTTPMethod        `json:"http_method" xorm:"tinyint notnull default 1"`               // http请求方法
+	Timeout           int                   `json:"timeout" xorm:"mediumint notnull default 0"`                 // 任务执行超时时间(单位秒),0不限制
+	Multi             int8                  `json:"multi" xorm:"tinyint notnull default 1"`                     // 是否允许多实例运行
+	RetryTimes        int8                  `json:"retry_times" xorm:"tinyint notnull default 0"`               // 重试次数
+	RetryInterval     int16                 `json:"retry_interval" xorm:"smallint notnull default 0"`           // 重试间隔时间
+	NotifyStatus      int8                  `json:"notify_status" xorm:"tinyint notnull default 1"`             // 任务执行结束是否通知 0: 不通知 1: 失败通知 2: 执行结束通知 3: 任务执行结果关键字匹配通知
+	NotifyType        int8                  `json:"notify_type" xorm:"tinyint notnull default 0"`               // 通知类型 1: 邮件 2: slack 3: webhook
+	NotifyReceiverId  string                `json:"notify_receiver_id" xorm:"varchar(256) notnull default '' "` // 通知接受者ID, setting表主键ID，多个ID逗号分隔
+	NotifyKeyword     string                `json:"notify_keyword" xorm:"varchar(128) notnull default '' "`
+	InterruptPolicy   TaskInterruptPolicy   `json:"interrupt_policy" xorm:"tinyint notnull default 0"` // 调度器重启导致执行中断后的处理 0: 不处理 1: 重新执行
+	MisfirePolicy     TaskMisfirePolicy     `json:"misfire_policy" xorm:"tinyint notnull default 0"`   // 调度器停止期间错过的调度 0: 跳过 1: 补执行一次 2: 补执行所有
+	MisfireLimit      int16                 `json:"misfire_limit" xorm:"smallint notnull default 0"`   // 补执行所有时的上限次数
+	Tag               string                `json:"tag" xorm:"varchar(32) notnull default ''"`
+	Remark            string                `json:"remark" xorm:"varchar(100) notnull default ''"` // 备注
+	Status            Status                `json:"status" xorm:"tinyint notnull index default 0"` // 状态 1:正常 0:停止
+	Created           time.Time             `json:"created" xorm:"datetime notnull created"`       // 创建时间
+	Deleted           time.Time             `json:"deleted" xorm:"datetime deleted"`               // 删除时间
+	BaseModel         `json:"-" xorm:"-"`
+	Hosts             []TaskHostDetail  `json:"hosts" xorm:"-"`
+	NextRunTime       time.Time         `json:"next_run_time" xorm:"-"`
+	WorkflowRunId     int64             `json:"-" xorm:"-"` // 本次执行所属的工作流
+	RunContext        map[string]string `json:"-" xorm:"-"` // 本次执行的上下文, 依赖任务执行时包含上游任务的执行结果
 }
 
 func taskHostTableName() []string {
@@ -83,7 +153,7 @@ func (task *Task) UpdateBean(id int) (int64, error) {
 	return Db.ID(id).
 		Cols(`name,spec,protocol,command,timeout,multi,
 			retry_times,retry_interval,remark,notify_status,
-			notify_type,notify_receiver_id, dependency_task_id, dependency_status, tag,http_method, notify_keyword`).
+			notify_type,notify_receiver_id, dependency_task_id, dependency_status, tag,http_method, notify_keyword, interrupt_policy, misfire_policy, misfire_limit, timezone, dependency_trigger, env, work_dir, shell, run_as_user, run_as_group, cpu_limit, memory_limit, pids_limit, stop_signal, stop_grace, host_selector, dispatch_strategy, success_policy, success_threshold, script, interpreter`).
 		Update(task)
 }
 
@@ -233,6 +303,14 @@ func (task *Task) GetDependencyTaskList(ids string) ([]Task, error) {
 	return task.setHostsForTasks(list)
 }
 
+// 获取所有任务的子任务ID, 用于检测循环依赖
+func (task *Task) DependencyList() ([]Task, error) {
+	list := make([]Task, 0)
+	err := Db.Where("dependency_task_id != ''").Cols("id", "dependency_task_id").Find(&list)
+
+	return list, err
+}
+
 func (task *Task) Total(params CommonMap) (int64, error) {
 	session := Db.Alias("t").Join("LEFT", taskHostTableName(), "t.id = th.task_id")
 	task.parseWhere(session, params)
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+// 任务变更记录, 集群模式下各实例轮询变更记录同步调度器
+
+type TaskChange struct {
+	Id       int64     `json:"id" xorm:"bigint pk autoincr"`
+	TaskId   int       `json:"task_id" xorm:"int notnull index"`
+	Instance string    `json:"instance" xorm:"varchar(128) notnull default '' "` // 产生变更的实例
+	Created  time.Time `json:"created" xorm:"datetime notnull created"`
+}
+
+// 批量记录任务变更
+func (change *TaskChange) Create(instance string, taskIds ...int) error {
+	if len(taskIds) == 0 {
+		return nil
+	}
+	changes := make([]TaskChange, len(taskIds))
+	for i, taskId := range taskIds {
+		changes[i].TaskId = taskId
+		changes[i].Instance = instance
+	}
+	_, err := Db.Insert(&changes)
+
+	return err
+}
+
+// 获取指定ID之后的变更记录
+func (change *TaskChange) ListAfter(id int64, limit int) ([]TaskChange, error) {
+	list := make([]TaskChange, 0)
+	err := Db.Where("id > ?", id).Asc("id").Limit(limit).Find(&list)
+
+	return list, err
+}
+
+// 最新变更记录ID
+func (change *TaskChange) LastId() (int64, error) {
+	last := new(TaskChange)
+	_, err := Db.Desc("id").Get(last)
+
+	return last.Id, err
+}
+
+// 删除指定时间之前的变更记录
+func (change *TaskChange) RemoveBefore(t time.Time) (int64, error) {
+	return Db.Where("created < ?", t.Format(DefaultTimeFormat)).Delete(change)
+}
@@ -52,6 +52,7 @@ func (th *TaskHost) GetHostIdsByTaskId(taskId int) ([]TaskHostDetail, error) {
 		Join("LEFT", hostTableName(), "th.host_id=h.id").
 		Where("th.task_id = ?", taskId).
 		Cols(fields).
+		Asc("th.id").
 		Find(&list)
 
 	return list, err
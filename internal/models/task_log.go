@@ -10,21 +10,32 @@ type TaskType int8
 
 // 任务执行日志
 type TaskLog struct {
-	Id         int64        `json:"id" xorm:"bigint pk autoincr"`
-	TaskId     int          `json:"task_id" xorm:"int notnull index default 0"`       // 任务id
-	Name       string       `json:"name" xorm:"varchar(32) notnull"`                  // 任务名称
-	Spec       string       `json:"spec" xorm:"varchar(64) notnull"`                  // crontab
-	Protocol   TaskProtocol `json:"protocol" xorm:"tinyint notnull index"`            // 协议 1:http 2:RPC
-	Command    string       `json:"command" xorm:"varchar(256) notnull"`              // URL地址或shell命令
-	Timeout    int          `json:"timeout" xorm:"mediumint notnull default 0"`       // 任务执行超时时间(单位秒),0不限制
-	RetryTimes int8         `json:"retry_times" xorm:"tinyint notnull default 0"`     // 任务重试次数
-	Hostname   string       `json:"hostname" xorm:"varchar(128) notnull default '' "` // RPC主机名，逗号分隔
-	StartTime  time.Time    `json:"start_time" xorm:"datetime created"`               // 开始执行时间
-	EndTime    time.Time    `json:"end_time" xorm:"datetime updated"`                 // 执行完成（失败）时间
-	Status     Status       `json:"status" xorm:"tinyint notnull index default 1"`    // 状态 0:执行失败 1:执行中  2:执行完毕 3:任务取消(上次任务未执行完成) 4:异步执行
-	Result     string       `json:"result" xorm:"mediumtext notnull "`                // 执行结果
-	TotalTime  int          `json:"total_time" xorm:"-"`                              // 执行总时长
-	BaseModel  `json:"-" xorm:"-"`
+	Id            int64         `json:"id" xorm:"bigint pk autoincr"`
+	TaskId        int           `json:"task_id" xorm:"int notnull index default 0"`            // 任务id
+	Name          string        `json:"name" xorm:"varchar(32) notnull"`                       // 任务名称
+	Spec          string        `json:"spec" xorm:"varchar(64) notnull"`                       // crontab
+	Protocol      TaskProtocol  `json:"protocol" xorm:"tinyint notnull index"`                 // 协议 1:http 2:RPC 3:脚本
+	Command       string        `json:"command" xorm:"varchar(256) notnull"`                   // URL地址或shell命令
+	Timeout       int           `json:"timeout" xorm:"mediumint notnull default 0"`            // 任务执行超时时间(单位秒),0不限制
+	RetryTimes    int8          `json:"retry_times" xorm:"tinyint notnull default 0"`          // 任务重试次数
+	Hostname      string        `json:"hostname" xorm:"varchar(128) notnull default '' "`      // RPC主机名，逗号分隔
+	StartTime     time.Time     `json:"start_time" xorm:"datetime created"`                    // 开始执行时间
+	EndTime       time.Time     `json:"end_time" xorm:"datetime updated"`                      // 执行完成（失败）时间
+	Status        Status        `json:"status" xorm:"tinyint notnull index default 1"`         // 状态 0:执行失败 1:执行中  2:执行完毕 3:任务取消(上次任务未执行完成) 4:执行中断 5:排队中
+	Instance      string        `json:"instance" xorm:"varchar(128) notnull default '' "`      // 调度该任务的gocron实例
+	WorkflowRunId int64         `json:"workflow_run_id" xorm:"bigint notnull index default 0"` // 所属工作流执行记录ID, 0: 不属于工作流
+	Result        string        `json:"result" xorm:"mediumtext notnull "`                     // 执行结果
+	Stdout        string        `json:"stdout" xorm:"mediumtext"`                              // 标准输出
+	Stderr        string        `json:"stderr" xorm:"mediumtext"`                              // 错误输出
+	ExitCode      int           `json:"exit_code" xorm:"int notnull default 0"`                // 退出码, 被信号终止时为-1
+	Signal        string        `json:"signal" xorm:"varchar(16) notnull default '' "`         // 终止进程的信号
+	PeakMemory    int64         `json:"peak_memory" xorm:"bigint notnull default 0"`           // shell任务内存使用峰值(字节), 多个主机时为最大值
+	CpuTime       int64         `json:"cpu_time" xorm:"bigint notnull default 0"`              // shell任务CPU时间(毫秒), 多个主机时为总和
+	OutputFile    string        `json:"output_file" xorm:"varchar(256) notnull default '' "`   // 完整输出文件, 相对日志目录的路径, 输出超出最大长度时保存
+	ScriptHash    string        `json:"script_hash" xorm:"varchar(64) notnull default '' "`    // 脚本任务执行的脚本内容sha256
+	TotalTime     int           `json:"total_time" xorm:"-"`                                   // 执行总时长
+	Hosts         []TaskLogHost `json:"hosts" xorm:"-"`                                        // RPC任务在各主机的执行记录
+	BaseModel     `json:"-" xorm:"-"`
 }
 
 func (taskLog *TaskLog) Create() (insertId int64, err error) {
@@ -41,6 +52,66 @@ func (taskLog *TaskLog) Update(id int64, data CommonMap) (int64, error) {
 	return Db.Table(taskLog).ID(id).Update(data)
 }
 
+// 未执行完成的任务标记为中断, 任务已执行完成时返回false
+func (taskLog *TaskLog) Interrupt(id int64, result string) (bool, error) {
+	affected, err := Db.Table(taskLog).
+		Where("id = ?", id).
+		In("status", Queued, Running).
+		Update(CommonMap{
+			"status": Interrupted,
+			"result": result,
+		})
+
+	return affected > 0, err
+}
+
+// 排队中的任务开始执行, 任务已不在排队中时返回false
+func (taskLog *TaskLog) Claim(id int64) (bool, error) {
+	affected, err := Db.Table(taskLog).
+		Where("id = ? AND status = ?", id, Queued).
+		Update(CommonMap{
+			"status":     Running,
+			"start_time": time.Now().Format(DefaultTimeFormat),
+		})
+
+	return affected > 0, err
+}
+
+// 获取未执行完成(排队中、执行中)的任务日志, 未指定实例时返回所有实例的
+func (taskLog *TaskLog) UnfinishedList(instances ...string) ([]TaskLog, error) {
+	list := make([]TaskLog, 0)
+	session := Db.In("status", Queued, Running)
+	if len(instances) > 0 {
+		session.In("instance", instances)
+	}
+	err := session.Asc("id").Find(&list)
+
+	return list, err
+}
+
+// 获取已退出实例未执行完成的任务日志, alive为存活实例及其启动时间
+func (taskLog *TaskLog) OrphanList(alive map[string]time.Time) ([]TaskLog, error) {
+	list := make([]TaskLog, 0)
+	condition, args := orphanCondition(alive)
+	err := Db.In("status", Queued, Running).And(condition, args...).Asc("id").Find(&list)
+
+	return list, err
+}
+
+// 任务最近一次执行的开始时间, 无执行记录时返回零值
+func (taskLog *TaskLog) LastStartTime(taskId int) (time.Time, error) {
+	last := new(TaskLog)
+	_, err := Db.Where("task_id = ?", taskId).Desc("id").Cols("start_time").Get(last)
+
+	return last.StartTime, err
+}
+
+func (taskLog *TaskLog) Find(id int64) error {
+	_, err := Db.Id(id).Get(taskLog)
+
+	return err
+}
+
 func (taskLog *TaskLog) List(params CommonMap) ([]TaskLog, error) {
 	taskLog.parsePageAndPageSize(params)
 	list := make([]TaskLog, 0)
@@ -50,24 +121,75 @@ func (taskLog *TaskLog) List(params CommonMap) ([]TaskLog, error) {
 	if len(list) > 0 {
 		for i, item := range list {
 			endTime := item.EndTime
-			if item.Status == Running {
+			if item.Status == Running || item.Status == Queued {
 				endTime = time.Now()
 			}
 			execSeconds := endTime.Sub(item.StartTime).Seconds()
 			list[i].TotalTime = int(execSeconds)
 		}
+		err = taskLog.attachHosts(list)
 	}
 
 	return list, err
 }
 
-// 清空表
+// 附加各主机的执行记录
+func (taskLog *TaskLog) attachHosts(list []TaskLog) error {
+	ids := make([]int64, 0, len(list))
+	for _, item := range list {
+		if item.Protocol.OnHost() {
+			ids = append(ids, item.Id)
+		}
+	}
+	hosts, err := new(TaskLogHost).ListByTaskLogIds(ids)
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		list[i].Hosts = make([]TaskLogHost, 0)
+		for _, host := range hosts {
+			if host.TaskLogId == list[i].Id {
+				list[i].Hosts = append(list[i].Hosts, host)
+			}
+		}
+	}
+
+	return nil
+}
+
+// 清空表, 同时清空各主机的执行记录
 func (taskLog *TaskLog) Clear() (int64, error) {
+	_, err := new(TaskLogHost).Clear()
+	if err != nil {
+		return 0, err
+	}
+
 	return Db.Where("1=1").Delete(taskLog)
 }
 
-// 删除N个月前的日志
+// 完整输出文件列表, month大于0时只返回N个月前的日志
+func (taskLog *TaskLog) OutputFiles(month int) ([]string, error) {
+	list := make([]TaskLog, 0)
+	session := Db.Cols("output_file").Where("output_file != ''")
+	if month > 0 {
+		t := time.Now().AddDate(0, -month, 0)
+		session.And("start_time <= ?", t.Format(DefaultTimeFormat))
+	}
+	err := session.Find(&list)
+	files := make([]string, len(list))
+	for i, item := range list {
+		files[i] = item.OutputFile
+	}
+
+	return files, err
+}
+
+// 删除N个月前的日志, 同时删除各主机的执行记录
 func (taskLog *TaskLog) Remove(id int) (int64, error) {
+	_, err := new(TaskLogHost).Remove(id)
+	if err != nil {
+		return 0, err
+	}
 	t := time.Now().AddDate(0, -id, 0)
 	return Db.Where("start_time <= ?", t.Format(DefaultTimeFormat)).Delete(taskLog)
 }
@@ -96,4 +218,8 @@ func (taskLog *TaskLog) parseWhere(session *xorm.Session, params CommonMap) {
 	if ok && status.(int) > -1 {
 		session.And("status = ?", status)
 	}
+	workflowRunId, ok := params["WorkflowRunId"]
+	if ok && workflowRunId.(int64) > 0 {
+		session.And("workflow_run_id = ?", workflowRunId)
+	}
 }
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+)
+
+// RPC任务在各主机的执行记录
+type TaskLogHost struct {
+	Id        int64     `json:"id" xorm:"bigint pk autoincr"`
+	TaskLogId int64     `json:"task_log_id" xorm:"bigint notnull index"`         // 任务日志ID
+	HostId    int16     `json:"host_id" xorm:"smallint notnull index default 0"` // 主机ID
+	Alias     string    `json:"alias" xorm:"varchar(32) notnull default '' "`    // 执行时的主机别名
+	Name      string    `json:"name" xorm:"varchar(64) notnull default '' "`     // 执行时的主机名
+	Port      int       `json:"port" xorm:"notnull default 0"`                   // 执行时的主机端口
+	Status    Status    `json:"status" xorm:"tinyint notnull default 1"`         // 状态 0:执行失败 1:执行中 2:执行完毕 4:执行中断
+	StartTime time.Time `json:"start_time" xorm:"datetime"`                      // 开始执行时间
+	EndTime   time.Time `json:"end_time" xorm:"datetime"`                        // 执行完成（失败）时间
+	Output    string    `json:"output,omitempty" xorm:"mediumtext"`              // 输出
+	Error     string    `json:"error" xorm:"text"`                               // 错误信息
+	ExitCode  int       `json:"exit_code" xorm:"int notnull default 0"`          // 退出码, 被信号终止时为-1
+	Signal    string    `json:"signal" xorm:"varchar(16) notnull default '' "`   // 终止进程的信号
+}
+
+func (h *TaskLogHost) Create() (insertId int64, err error) {
+	_, err = Db.Insert(h)
+	if err == nil {
+		insertId = h.Id
+	}
+
+	return
+}
+
+func (h *TaskLogHost) Update(id int64, data CommonMap) (int64, error) {
+	return Db.Table(h).ID(id).Update(data)
+}
+
+func (h *TaskLogHost) Find(id int64) error {
+	_, err := Db.Id(id).Get(h)
+
+	return err
+}
+
+// 任务日志的各主机执行记录, 不含输出
+func (h *TaskLogHost) ListByTaskLogIds(taskLogIds []int64) ([]TaskLogHost, error) {
+	list := make([]TaskLogHost, 0)
+	if len(taskLogIds) == 0 {
+		return list, nil
+	}
+	err := Db.In("task_log_id", taskLogIds).Omit("output").Asc("id").Find(&list)
+
+	return list, err
+}
+
+// 任务日志中执行中的主机
+func (h *TaskLogHost) RunningList(taskLogId int64) ([]TaskLogHost, error) {
+	list := make([]TaskLogHost, 0)
+	err := Db.Where("task_log_id = ? AND status = ?", taskLogId, Running).Omit("output").Asc("id").Find(&list)
+
+	return list, err
+}
+
+// 调度器重启时执行中的记录标记为中断
+func (h *TaskLogHost) MarkInterrupted(taskLogIds []int64) (int64, error) {
+	if len(taskLogIds) == 0 {
+		return 0, nil
+	}
+
+	return Db.Table(h).In("task_log_id", taskLogIds).And("status = ?", Running).Update(CommonMap{
+		"status":   Interrupted,
+		"end_time": time.Now().Format(DefaultTimeFormat),
+	})
+}
+
+// 清空表
+func (h *TaskLogHost) Clear() (int64, error) {
+	return Db.Where("1=1").Delete(h)
+}
+
+// 删除N个月前的记录
+func (h *TaskLogHost) Remove(month int) (int64, error) {
+	t := time.Now().AddDate(0, -month, 0)
+	return Db.Where("start_time <= ?", t.Format(DefaultTimeFormat)).Delete(h)
+}
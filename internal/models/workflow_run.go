@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-xorm/xorm"
+)
+
+// 工作流执行记录, 有子任务的任务每次执行生成一条记录, 所有任务日志通过workflow_run_id关联
+
+type WorkflowRun struct {
+	Id        int64     `json:"id" xorm:"bigint pk autoincr"`
+	TaskId    int       `json:"task_id" xorm:"int notnull index default 0"`       // 起始任务ID
+	Name      string    `json:"name" xorm:"varchar(32) notnull"`                  // 起始任务名称
+	Spec      string    `json:"spec" xorm:"varchar(64) notnull"`                  // 起始任务的触发方式
+	TaskNum   int       `json:"task_num" xorm:"int notnull default 0"`            // 工作流包含的任务数
+	Status    Status    `json:"status" xorm:"tinyint notnull index default 1"`    // 状态 0:执行失败(存在失败或未执行的任务) 1:执行中 2:执行完毕 4:执行中断
+	Instance  string    `json:"instance" xorm:"varchar(128) notnull default '' "` // 调度该工作流的gocron实例
+	StartTime time.Time `json:"start_time" xorm:"datetime created"`               // 开始执行时间
+	EndTime   time.Time `json:"end_time" xorm:"datetime updated"`                 // 执行完成时间
+	TotalTime int       `json:"total_time" xorm:"-"`                              // 执行总时长
+	BaseModel `json:"-" xorm:"-"`
+}
+
+func (run *WorkflowRun) Create() (insertId int64, err error) {
+	_, err = Db.Insert(run)
+	if err == nil {
+		insertId = run.Id
+	}
+
+	return
+}
+
+// 更新
+func (run *WorkflowRun) Update(id int64, data CommonMap) (int64, error) {
+	return Db.Table(run).ID(id).Update(data)
+}
+
+// 执行中的工作流标记为中断, 未指定实例时处理所有实例的
+func (run *WorkflowRun) MarkInterrupted(instances ...string) (int64, error) {
+	session := Db.Table(run).Where("status = ?", Running)
+	if len(instances) > 0 {
+		session.In("instance", instances)
+	}
+
+	return session.Update(CommonMap{"status": Interrupted})
+}
+
+// 已退出实例执行中的工作流标记为中断, alive为存活实例及其启动时间
+func (run *WorkflowRun) MarkOrphanInterrupted(alive map[string]time.Time) (int64, error) {
+	condition, args := orphanCondition(alive)
+
+	return Db.Table(run).Where("status = ?", Running).And(condition, args...).Update(CommonMap{"status": Interrupted})
+}
+
+func (run *WorkflowRun) List(params CommonMap) ([]WorkflowRun, error) {
+	run.parsePageAndPageSize(params)
+	list := make([]WorkflowRun, 0)
+	session := Db.Desc("id")
+	run.parseWhere(session, params)
+	err := session.Limit(run.PageSize, run.pageLimitOffset()).Find(&list)
+	for i, item := range list {
+		endTime := item.EndTime
+		if item.Status == Running {
+			endTime = time.Now()
+		}
+		list[i].TotalTime = int(endTime.Sub(item.StartTime).Seconds())
+	}
+
+	return list, err
+}
+
+func (run *WorkflowRun) Total(params CommonMap) (int64, error) {
+	session := Db.NewSession()
+	defer session.Close()
+	run.parseWhere(session, params)
+
+	return session.Count(run)
+}
+
+// 解析where
+func (run *WorkflowRun) parseWhere(session *xorm.Session, params CommonMap) {
+	if len(params) == 0 {
+		return
+	}
+	taskId, ok := params["TaskId"]
+	if ok && taskId.(int) > 0 {
+		session.And("task_id = ?", taskId)
+	}
+	status, ok := params["Status"]
+	if ok && status.(int) > -1 {
+		session.And("status = ?", status)
+	}
+}
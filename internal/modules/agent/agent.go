@@ -0,0 +1,115 @@
+// Package agent gocron-node向gocron注册并定时发送心跳
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ouqiang/gocron/internal/modules/httpclient"
+	"github.com/ouqiang/gocron/internal/modules/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const requestTimeout = 10
+
+// gocron返回的任务节点未注册
+var errNotRegistered = errors.New("agent is not registered")
+
+type Agent struct {
+	ServerURL string        // gocron地址, 如 http://gocron:5920
+	Token     string        // 与gocron配置的agent.token一致
+	Id        string        // 任务节点标识, 重启后不变, 地址变化时更新原主机记录
+	Addr      string        // gocron连接任务节点使用的地址, 为空时使用请求的来源IP
+	Port      int           // 任务节点监听端口
+	Labels    string        // 标签, 逗号分隔, 如 env=prod,zone=a
+	Version   string        // 任务节点版本
+	Interval  time.Duration // 心跳间隔
+}
+
+type response struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// 注册成功后定时发送心跳, 注册失败时重试, gocron返回未注册时重新注册
+func (a Agent) Run() {
+	registered := false
+	for {
+		var err error
+		if registered {
+			err = a.heartbeat()
+			if err == errNotRegistered {
+				log.Warn("agent is not registered on gocron, register again")
+				registered = false
+			}
+		}
+		if !registered {
+			err = a.register()
+			registered = err == nil
+			if registered {
+				log.Infof("agent registered: [id: %s server: %s]", a.Id, a.ServerURL)
+			}
+		}
+		if err != nil {
+			log.Errorf("agent %s", err)
+		}
+		time.Sleep(a.Interval)
+	}
+}
+
+func (a Agent) register() error {
+	params := url.Values{}
+	params.Set("agent_id", a.Id)
+	params.Set("name", a.Addr)
+	params.Set("port", strconv.Itoa(a.Port))
+	params.Set("alias", a.Id)
+	params.Set("labels", a.Labels)
+	params.Set("version", a.Version)
+	params.Set("os", runtime.GOOS+"/"+runtime.GOARCH)
+	_, err := a.post("/api/agent/register", params)
+	if err != nil {
+		return fmt.Errorf("register failed: %s", err)
+	}
+
+	return nil
+}
+
+func (a Agent) heartbeat() error {
+	params := url.Values{}
+	params.Set("agent_id", a.Id)
+	resp, err := a.post("/api/agent/heartbeat", params)
+	if resp.Code == utils.NotFound {
+		return errNotRegistered
+	}
+	if err != nil {
+		return fmt.Errorf("heartbeat failed: %s", err)
+	}
+
+	return nil
+}
+
+func (a Agent) post(path string, params url.Values) (response, error) {
+	var resp response
+	header := http.Header{}
+	header.Set("Agent-Token", a.Token)
+	wrapper := httpclient.PostParams(strings.TrimRight(a.ServerURL, "/")+path, params.Encode(), requestTimeout, header)
+	if wrapper.StatusCode != http.StatusOK {
+		return resp, fmt.Errorf("status code %d %s", wrapper.StatusCode, wrapper.Body)
+	}
+	err := json.Unmarshal([]byte(wrapper.Body), &resp)
+	if err != nil {
+		return resp, fmt.Errorf("invalid response %s", wrapper.Body)
+	}
+	if resp.Code != utils.ResponseSuccess {
+		return resp, errors.New(resp.Message)
+	}
+
+	return resp, nil
+}
@@ -32,6 +32,8 @@ var (
 	VersionId int // 版本号
 	// VersionFile 版本文件
 	VersionFile string // 版本号文件
+	// Port web服务端口
+	Port int // web服务端口
 )
 
 // InitEnv 初始化
@@ -61,6 +63,15 @@ func IsInstalled() bool {
 	return true
 }
 
+// InitInstance 实例标识未配置时默认为 主机名:端口
+func InitInstance(s *setting.Setting) {
+	if s.Cluster.Instance != "" {
+		return
+	}
+	hostname, _ := os.Hostname()
+	s.Cluster.Instance = fmt.Sprintf("%s:%d", hostname, Port)
+}
+
 // CreateInstallLock 创建安装锁文件
 func CreateInstallLock() error {
 	_, err := os.Create(filepath.Join(ConfDir, "/install.lock"))
@@ -16,21 +16,24 @@ type ResponseWrapper struct {
 	Header     http.Header
 }
 
-func Get(url string, timeout int) ResponseWrapper {
+// header为附加的请求头, 可为nil
+func Get(url string, timeout int, header http.Header) ResponseWrapper {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return createRequestError(err)
 	}
+	addRequestHeader(req, header)
 
 	return request(req, timeout)
 }
 
-func PostParams(url string, params string, timeout int) ResponseWrapper {
+func PostParams(url string, params string, timeout int, header http.Header) ResponseWrapper {
 	buf := bytes.NewBufferString(params)
 	req, err := http.NewRequest("POST", url, buf)
 	if err != nil {
 		return createRequestError(err)
 	}
+	addRequestHeader(req, header)
 	req.Header.Set("Content-type", "application/x-www-form-urlencoded")
 
 	return request(req, timeout)
@@ -76,6 +79,14 @@ func setRequestHeader(req *http.Request) {
 	req.Header.Set("User-Agent", "golang/gocron")
 }
 
+func addRequestHeader(req *http.Request, header http.Header) {
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+}
+
 func createRequestError(err error) ResponseWrapper {
 	errorMessage := fmt.Sprintf("创建HTTP请求错误-%s", err.Error())
 	return ResponseWrapper{0, errorMessage, make(http.Header)}
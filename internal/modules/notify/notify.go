@@ -69,7 +69,12 @@ func parseNotifyTemplate(notifyTemplate string, msg Message) string {
 		"TaskName": msg["name"],
 		"Status":   msg["status"],
 		"Result":   msg["output"],
-		"Remark": msg["remark"],
+		"Remark":   msg["remark"],
+		"Stdout":   msg["stdout"],
+		"Stderr":   msg["stderr"],
+		"ExitCode": msg["exit_code"],
+		"Signal":   msg["signal"],
+		"Summary":  msg["summary"],
 	})
 
 	return buf.String()
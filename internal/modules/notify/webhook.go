@@ -26,6 +26,9 @@ func (webHook *WebHook) Send(msg Message) {
 	logger.Debugf("%+v", webHookSetting)
 	msg["name"] = utils.EscapeJson(msg["name"].(string))
 	msg["output"] = utils.EscapeJson(msg["output"].(string))
+	msg["stdout"] = utils.EscapeJson(msg["stdout"].(string))
+	msg["stderr"] = utils.EscapeJson(msg["stderr"].(string))
+	msg["summary"] = utils.EscapeJson(msg["summary"].(string))
 	msg["content"] = parseNotifyTemplate(webHookSetting.Template, msg)
 	msg["content"] = html.UnescapeString(msg["content"].(string))
 	webHook.send(msg, webHookSetting.Url)
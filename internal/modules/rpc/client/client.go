@@ -3,6 +3,7 @@ package client
 import (
 	"errors"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/ouqiang/gocron/internal/modules/logger"
 	"github.com/ouqiang/gocron/internal/modules/rpc/grpcpool"
 	pb "github.com/ouqiang/gocron/internal/modules/rpc/proto"
+	"github.com/ouqiang/gocron/internal/modules/utils"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/codes"
 )
@@ -19,21 +21,76 @@ var (
 	taskMap sync.Map
 )
 
+const (
+	stopTimeout = 5 * time.Second
+	// 任务节点结束进程后返回结果的等待时间
+	stopMargin = 5 * time.Second
+	// 与任务节点的连接断开后重新连接的最长时间
+	reattachTimeout = 5 * time.Minute
+	// 重新连接的最大间隔时间
+	reattachMaxDelay = 10 * time.Second
+)
+
 var (
 	errUnavailable = errors.New("无法连接远程服务器")
+	// 任务已在任务节点开始执行, 之后连接断开且无法重新连接, 执行结果未知
+	errDisconnected = errors.New("与任务节点的连接断开且无法重新连接, 执行结果未知")
+	// 任务节点的并发数已满且等待队列已满, 任务未在任务节点执行
+	errNodeBusy = errors.New("任务节点繁忙, 等待队列已满")
 )
 
+// IsUnavailable 无法连接任务节点, 任务未在任务节点执行
+func IsUnavailable(err error) bool {
+	return err == errUnavailable
+}
+
+// IsNodeBusy 任务节点等待队列已满, 拒绝执行
+func IsNodeBusy(err error) bool {
+	return err == errNodeBusy
+}
+
 func generateTaskUniqueKey(ip string, port int, id int64) string {
 	return fmt.Sprintf("%s:%d:%d", ip, port, id)
 }
 
-func Stop(ip string, port int, id int64) {
+// 停止任务, 由任务节点按停止信号结束进程, 任务可由任意实例发起执行
+// 调用失败时, 本实例发起的执行断开连接; 任务节点上没有执行中的任务时返回错误
+func Stop(ip string, port int, id int64) error {
 	key := generateTaskUniqueKey(ip, port, id)
-	cancel, ok := taskMap.Load(key)
-	if !ok {
-		return
+	cancel, local := taskMap.Load(key)
+	addr := fmt.Sprintf("%s:%d", ip, port)
+	c, err := grpcpool.Pool.Get(addr)
+	if err == nil {
+		ctx, cancelStop := context.WithTimeout(context.Background(), stopTimeout)
+		defer cancelStop()
+		var resp *pb.StopResponse
+		resp, err = c.Stop(ctx, &pb.StopRequest{Id: id})
+		if err == nil && resp.Running {
+			return nil
+		}
+	}
+	if local {
+		cancel.(context.CancelFunc)()
+	}
+	if err == nil {
+		return errors.New("任务节点上没有执行中的任务")
 	}
-	cancel.(context.CancelFunc)()
+	logger.Warnf("任务节点停止任务失败#%s#%d#%s", addr, id, err)
+	if local {
+		return nil
+	}
+	_, err = parseGRPCError(err)
+
+	return err
+}
+
+// 调用超时时间, 任务节点超时结束进程后仍需等待停止信号的处理时间
+func execTimeout(taskReq *pb.TaskRequest) time.Duration {
+	if taskReq.Timeout <= 0 || taskReq.Timeout > 86400 {
+		taskReq.Timeout = 86400
+	}
+
+	return time.Duration(taskReq.Timeout+taskReq.StopGrace)*time.Second + stopMargin
 }
 
 func Exec(ip string, port int, taskReq *pb.TaskRequest) (string, error) {
@@ -47,27 +104,239 @@ func Exec(ip string, port int, taskReq *pb.TaskRequest) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if taskReq.Timeout <= 0 || taskReq.Timeout > 86400 {
-		taskReq.Timeout = 86400
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout(taskReq))
+	defer cancel()
+
+	taskUniqueKey := generateTaskUniqueKey(ip, port, taskReq.Id)
+	taskMap.Store(taskUniqueKey, cancel)
+	defer taskMap.Delete(taskUniqueKey)
+
+	return execUnary(ctx, addr, c, taskReq)
+}
+
+// 流式执行任务, 输出产生时回调onOutput, 节点不支持流式调用时使用Run执行
+// 返回的输出超出maxOutput(字节)时保留开头和末尾部分, 0不限制
+func ExecStream(ip string, port int, taskReq *pb.TaskRequest, maxOutput int, onOutput func(output string)) (resp *pb.TaskResponse, err error) {
+	resp = new(pb.TaskResponse)
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("panic#rpc/client.go:ExecStream#", r)
+			resp = new(pb.TaskResponse)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	addr := fmt.Sprintf("%s:%d", ip, port)
+	c, err := grpcpool.Pool.Get(addr)
+	if err != nil {
+		return resp, err
 	}
-	timeout := time.Duration(taskReq.Timeout) * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout(taskReq))
 	defer cancel()
 
 	taskUniqueKey := generateTaskUniqueKey(ip, port, taskReq.Id)
 	taskMap.Store(taskUniqueKey, cancel)
 	defer taskMap.Delete(taskUniqueKey)
 
+	var stream outputReceiver
+	stream, err = c.RunStream(ctx, taskReq)
+	if err != nil {
+		_, err = parseGRPCError(err)
+		return resp, err
+	}
+	output := &utils.CappedBuffer{MaxSize: maxOutput}
+	stdout := &utils.CappedBuffer{MaxSize: maxOutput}
+	stderr := &utils.CappedBuffer{MaxSize: maxOutput}
+	// 已接收的输出长度, 重新连接后从该位置继续获取输出
+	var received int64
+	for {
+		out, err := stream.Recv()
+		if err == io.EOF {
+			err = errors.New("节点未返回执行结果")
+		}
+		if err != nil && isDisconnected(ctx, err) {
+			logger.Warnf("任务节点连接断开, 尝试重新连接#%s#%d#%s", addr, taskReq.Id, err)
+			stream, err = reattach(ctx, addr, taskReq.Id, received, err)
+			if err == nil {
+				continue
+			}
+			if status.Code(err) == codes.Unavailable {
+				err = errDisconnected
+			}
+		}
+		if err != nil {
+			if status.Code(err) == codes.Unimplemented && output.Size() == 0 {
+				resp, err = execUnaryResponse(ctx, addr, c, taskReq)
+				resp.Output = utils.TruncateOutput(resp.Output, maxOutput)
+				resp.Stdout = utils.TruncateOutput(resp.Stdout, maxOutput)
+				resp.Stderr = utils.TruncateOutput(resp.Stderr, maxOutput)
+				return resp, err
+			}
+			_, err = parseGRPCError(err)
+			resp.Output = output.String()
+			return resp, err
+		}
+		// 任务节点保留的输出有限, 断开期间的部分输出可能已丢弃
+		if out.Offset > received {
+			lost := fmt.Sprintf("\n...... 连接断开期间丢失%d字节输出 ......\n", out.Offset-received)
+			output.Write([]byte(lost))
+			onOutput(lost)
+		}
+		// 旧版本任务节点不返回偏移量
+		if out.Offset >= received {
+			received = out.Offset
+		}
+		received += int64(len(out.Output))
+		if out.Output != "" {
+			output.Write([]byte(out.Output))
+			if out.Stderr {
+				stderr.Write([]byte(out.Output))
+			} else {
+				stdout.Write([]byte(out.Output))
+			}
+			onOutput(out.Output)
+		}
+		if out.Response == nil {
+			continue
+		}
+		resp = out.Response
+		resp.Output = output.String()
+		resp.Stdout = stdout.String()
+		resp.Stderr = stderr.String()
+		if resp.Error == "" {
+			return resp, nil
+		}
+
+		return resp, errors.New(resp.Error)
+	}
+}
+
+func execUnary(ctx context.Context, addr string, c pb.TaskClient, taskReq *pb.TaskRequest) (string, error) {
+	resp, err := execUnaryResponse(ctx, addr, c, taskReq)
+
+	return resp.Output, err
+}
+
+func execUnaryResponse(ctx context.Context, addr string, c pb.TaskClient, taskReq *pb.TaskRequest) (*pb.TaskResponse, error) {
 	resp, err := c.Run(ctx, taskReq)
+	if err != nil && isDisconnected(ctx, err) {
+		logger.Warnf("任务节点连接断开, 等待任务执行结束#%s#%d#%s", addr, taskReq.Id, err)
+		resp, err = waitResult(ctx, addr, taskReq.Id, err)
+	}
 	if err != nil {
-		return parseGRPCError(err)
+		_, err = parseGRPCError(err)
+		return new(pb.TaskResponse), err
 	}
 
 	if resp.Error == "" {
-		return resp.Output, nil
+		return resp, nil
+	}
+
+	return resp, errors.New(resp.Error)
+}
+
+type outputReceiver interface {
+	Recv() (*pb.TaskOutput, error)
+}
+
+// 任务执行期间与任务节点的连接断开, 任务仍在任务节点执行
+func isDisconnected(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && status.Code(err) == codes.Unavailable
+}
+
+// 重新连接任务节点, 获取偏移量之后的输出及执行结果
+func reattach(ctx context.Context, addr string, id int64, offset int64, cause error) (outputReceiver, error) {
+	c, _, err := nodeStatus(ctx, addr, id, cause)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("重新连接任务节点成功#%s#%d", addr, id)
+
+	return c.Attach(ctx, &pb.AttachRequest{Id: id, Offset: offset})
+}
+
+// 等待任务执行结束, 获取执行结果
+// 任务执行中时通过Attach等待执行结束, 不轮询执行状态, 连接再次断开时重新连接
+func waitResult(ctx context.Context, addr string, id int64, cause error) (*pb.TaskResponse, error) {
+	for {
+		c, taskStatus, err := nodeStatus(ctx, addr, id, cause)
+		if err != nil {
+			return nil, err
+		}
+		if taskStatus.Running {
+			err = waitAttach(ctx, c, id, taskStatus.OutputSize)
+			if err != nil && isDisconnected(ctx, err) {
+				cause = err
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return c.Result(ctx, &pb.ResultRequest{Id: id})
+	}
+}
+
+// 通过Attach等待任务执行结束, 只需要执行结果, 从offset开始获取以跳过已产生的输出
+func waitAttach(ctx context.Context, c pb.TaskClient, id int64, offset int64) error {
+	stream, err := c.Attach(ctx, &pb.AttachRequest{Id: id, Offset: offset})
+	if err != nil {
+		return err
+	}
+	for {
+		out, err := stream.Recv()
+		if err == io.EOF {
+			return errors.New("节点未返回执行结果")
+		}
+		if err != nil {
+			return err
+		}
+		if out.Response != nil {
+			return nil
+		}
+	}
+}
+
+// 查询任务在任务节点的执行状态, 任务节点不可用时重试, 超过reattachTimeout仍不可用时返回连接断开的错误
+func nodeStatus(ctx context.Context, addr string, id int64, cause error) (pb.TaskClient, *pb.StatusResponse, error) {
+	deadline := time.Now().Add(reattachTimeout)
+	delay := time.Second
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil, contextError(ctx)
+		case <-time.After(delay):
+		}
+		c, err := grpcpool.Pool.Get(addr)
+		if err == nil {
+			var taskStatus *pb.StatusResponse
+			taskStatus, err = c.Status(ctx, &pb.StatusRequest{Id: id})
+			if err == nil && !taskStatus.Found {
+				return nil, nil, errors.New("任务节点未找到执行记录, 任务节点可能已重启")
+			}
+			if err == nil {
+				return c, taskStatus, nil
+			}
+			// 旧版本任务节点不支持重新连接
+			if status.Code(err) == codes.Unimplemented {
+				return nil, nil, cause
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, nil, cause
+		}
+		if delay < reattachMaxDelay {
+			delay *= 2
+		}
+	}
+}
+
+func contextError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return status.Error(codes.DeadlineExceeded, ctx.Err().Error())
 	}
 
-	return resp.Output, errors.New(resp.Error)
+	return status.Error(codes.Canceled, ctx.Err().Error())
 }
 
 func parseGRPCError(err error) (string, error) {
@@ -78,6 +347,12 @@ func parseGRPCError(err error) (string, error) {
 		return "", errors.New("执行超时, 强制结束")
 	case codes.Canceled:
 		return "", errors.New("手动停止")
+	case codes.ResourceExhausted:
+		return "", errNodeBusy
+	case codes.PermissionDenied:
+		return "", fmt.Errorf("任务节点拒绝执行: %s", status.Convert(err).Message())
+	case codes.InvalidArgument:
+		return "", fmt.Errorf("任务参数错误: %s", status.Convert(err).Message())
 	}
 	return "", err
 }
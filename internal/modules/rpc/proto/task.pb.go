@@ -1,25 +1,14 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // source: task.proto
 
-/*
-Package rpc is a generated protocol buffer package.
-
-It is generated from these files:
-	task.proto
-
-It has these top-level messages:
-	TaskRequest
-	TaskResponse
-*/
 package rpc
 
-import proto "github.com/golang/protobuf/proto"
-import fmt "fmt"
-import math "math"
-
 import (
-	context "golang.org/x/net/context"
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
 	grpc "google.golang.org/grpc"
+	math "math"
 )
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -31,18 +20,53 @@ var _ = math.Inf
 // is compatible with the proto package it is being compiled against.
 // A compilation error at this line likely means your copy of the
 // proto package needs to be updated.
-const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type TaskRequest struct {
-	Command string `protobuf:"bytes,2,opt,name=command" json:"command,omitempty"`
-	Timeout int32  `protobuf:"varint,3,opt,name=timeout" json:"timeout,omitempty"`
-	Id      int64  `protobuf:"varint,4,opt,name=id" json:"id,omitempty"`
+	Command              string            `protobuf:"bytes,2,opt,name=command,proto3" json:"command,omitempty"`
+	Timeout              int32             `protobuf:"varint,3,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	Id                   int64             `protobuf:"varint,4,opt,name=id,proto3" json:"id,omitempty"`
+	Env                  map[string]string `protobuf:"bytes,5,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Shell                string            `protobuf:"bytes,6,opt,name=shell,proto3" json:"shell,omitempty"`
+	WorkDir              string            `protobuf:"bytes,7,opt,name=work_dir,json=workDir,proto3" json:"work_dir,omitempty"`
+	User                 string            `protobuf:"bytes,8,opt,name=user,proto3" json:"user,omitempty"`
+	Group                string            `protobuf:"bytes,9,opt,name=group,proto3" json:"group,omitempty"`
+	CpuLimit             int32             `protobuf:"varint,10,opt,name=cpu_limit,json=cpuLimit,proto3" json:"cpu_limit,omitempty"`
+	MemoryLimit          int32             `protobuf:"varint,11,opt,name=memory_limit,json=memoryLimit,proto3" json:"memory_limit,omitempty"`
+	PidsLimit            int32             `protobuf:"varint,12,opt,name=pids_limit,json=pidsLimit,proto3" json:"pids_limit,omitempty"`
+	StopSignal           string            `protobuf:"bytes,13,opt,name=stop_signal,json=stopSignal,proto3" json:"stop_signal,omitempty"`
+	StopGrace            int32             `protobuf:"varint,14,opt,name=stop_grace,json=stopGrace,proto3" json:"stop_grace,omitempty"`
+	Script               string            `protobuf:"bytes,15,opt,name=script,proto3" json:"script,omitempty"`
+	Interpreter          string            `protobuf:"bytes,16,opt,name=interpreter,proto3" json:"interpreter,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *TaskRequest) Reset()         { *m = TaskRequest{} }
+func (m *TaskRequest) String() string { return proto.CompactTextString(m) }
+func (*TaskRequest) ProtoMessage()    {}
+func (*TaskRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ce5d8dd45b4a91ff, []int{0}
+}
+
+func (m *TaskRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TaskRequest.Unmarshal(m, b)
+}
+func (m *TaskRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TaskRequest.Marshal(b, m, deterministic)
+}
+func (m *TaskRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TaskRequest.Merge(m, src)
+}
+func (m *TaskRequest) XXX_Size() int {
+	return xxx_messageInfo_TaskRequest.Size(m)
+}
+func (m *TaskRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TaskRequest.DiscardUnknown(m)
 }
 
-func (m *TaskRequest) Reset()                    { *m = TaskRequest{} }
-func (m *TaskRequest) String() string            { return proto.CompactTextString(m) }
-func (*TaskRequest) ProtoMessage()               {}
-func (*TaskRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+var xxx_messageInfo_TaskRequest proto.InternalMessageInfo
 
 func (m *TaskRequest) GetCommand() string {
 	if m != nil {
@@ -65,15 +89,128 @@ func (m *TaskRequest) GetId() int64 {
 	return 0
 }
 
+func (m *TaskRequest) GetEnv() map[string]string {
+	if m != nil {
+		return m.Env
+	}
+	return nil
+}
+
+func (m *TaskRequest) GetShell() string {
+	if m != nil {
+		return m.Shell
+	}
+	return ""
+}
+
+func (m *TaskRequest) GetWorkDir() string {
+	if m != nil {
+		return m.WorkDir
+	}
+	return ""
+}
+
+func (m *TaskRequest) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *TaskRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *TaskRequest) GetCpuLimit() int32 {
+	if m != nil {
+		return m.CpuLimit
+	}
+	return 0
+}
+
+func (m *TaskRequest) GetMemoryLimit() int32 {
+	if m != nil {
+		return m.MemoryLimit
+	}
+	return 0
+}
+
+func (m *TaskRequest) GetPidsLimit() int32 {
+	if m != nil {
+		return m.PidsLimit
+	}
+	return 0
+}
+
+func (m *TaskRequest) GetStopSignal() string {
+	if m != nil {
+		return m.StopSignal
+	}
+	return ""
+}
+
+func (m *TaskRequest) GetStopGrace() int32 {
+	if m != nil {
+		return m.StopGrace
+	}
+	return 0
+}
+
+func (m *TaskRequest) GetScript() string {
+	if m != nil {
+		return m.Script
+	}
+	return ""
+}
+
+func (m *TaskRequest) GetInterpreter() string {
+	if m != nil {
+		return m.Interpreter
+	}
+	return ""
+}
+
 type TaskResponse struct {
-	Output string `protobuf:"bytes,1,opt,name=output" json:"output,omitempty"`
-	Error  string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	Output               string   `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
+	Error                string   `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Stdout               string   `protobuf:"bytes,3,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr               string   `protobuf:"bytes,4,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	ExitCode             int32    `protobuf:"varint,5,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	Signal               string   `protobuf:"bytes,6,opt,name=signal,proto3" json:"signal,omitempty"`
+	PeakMemory           int64    `protobuf:"varint,7,opt,name=peak_memory,json=peakMemory,proto3" json:"peak_memory,omitempty"`
+	CpuTime              int64    `protobuf:"varint,8,opt,name=cpu_time,json=cpuTime,proto3" json:"cpu_time,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TaskResponse) Reset()                    { *m = TaskResponse{} }
-func (m *TaskResponse) String() string            { return proto.CompactTextString(m) }
-func (*TaskResponse) ProtoMessage()               {}
-func (*TaskResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (m *TaskResponse) Reset()         { *m = TaskResponse{} }
+func (m *TaskResponse) String() string { return proto.CompactTextString(m) }
+func (*TaskResponse) ProtoMessage()    {}
+func (*TaskResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ce5d8dd45b4a91ff, []int{1}
+}
+
+func (m *TaskResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TaskResponse.Unmarshal(m, b)
+}
+func (m *TaskResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TaskResponse.Marshal(b, m, deterministic)
+}
+func (m *TaskResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TaskResponse.Merge(m, src)
+}
+func (m *TaskResponse) XXX_Size() int {
+	return xxx_messageInfo_TaskResponse.Size(m)
+}
+func (m *TaskResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_TaskResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TaskResponse proto.InternalMessageInfo
 
 func (m *TaskResponse) GetOutput() string {
 	if m != nil {
@@ -89,9 +226,439 @@ func (m *TaskResponse) GetError() string {
 	return ""
 }
 
+func (m *TaskResponse) GetStdout() string {
+	if m != nil {
+		return m.Stdout
+	}
+	return ""
+}
+
+func (m *TaskResponse) GetStderr() string {
+	if m != nil {
+		return m.Stderr
+	}
+	return ""
+}
+
+func (m *TaskResponse) GetExitCode() int32 {
+	if m != nil {
+		return m.ExitCode
+	}
+	return 0
+}
+
+func (m *TaskResponse) GetSignal() string {
+	if m != nil {
+		return m.Signal
+	}
+	return ""
+}
+
+func (m *TaskResponse) GetPeakMemory() int64 {
+	if m != nil {
+		return m.PeakMemory
+	}
+	return 0
+}
+
+func (m *TaskResponse) GetCpuTime() int64 {
+	if m != nil {
+		return m.CpuTime
+	}
+	return 0
+}
+
+type TaskOutput struct {
+	Output               string        `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
+	Response             *TaskResponse `protobuf:"bytes,2,opt,name=response,proto3" json:"response,omitempty"`
+	Stderr               bool          `protobuf:"varint,3,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	Offset               int64         `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *TaskOutput) Reset()         { *m = TaskOutput{} }
+func (m *TaskOutput) String() string { return proto.CompactTextString(m) }
+func (*TaskOutput) ProtoMessage()    {}
+func (*TaskOutput) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ce5d8dd45b4a91ff, []int{2}
+}
+
+func (m *TaskOutput) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TaskOutput.Unmarshal(m, b)
+}
+func (m *TaskOutput) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TaskOutput.Marshal(b, m, deterministic)
+}
+func (m *TaskOutput) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TaskOutput.Merge(m, src)
+}
+func (m *TaskOutput) XXX_Size() int {
+	return xxx_messageInfo_TaskOutput.Size(m)
+}
+func (m *TaskOutput) XXX_DiscardUnknown() {
+	xxx_messageInfo_TaskOutput.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TaskOutput proto.InternalMessageInfo
+
+func (m *TaskOutput) GetOutput() string {
+	if m != nil {
+		return m.Output
+	}
+	return ""
+}
+
+func (m *TaskOutput) GetResponse() *TaskResponse {
+	if m != nil {
+		return m.Response
+	}
+	return nil
+}
+
+func (m *TaskOutput) GetStderr() bool {
+	if m != nil {
+		return m.Stderr
+	}
+	return false
+}
+
+func (m *TaskOutput) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+type StopRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StopRequest) Reset()         { *m = StopRequest{} }
+func (m *StopRequest) String() string { return proto.CompactTextString(m) }
+func (*StopRequest) ProtoMessage()    {}
+func (*StopRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ce5d8dd45b4a91ff, []int{3}
+}
+
+func (m *StopRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StopRequest.Unmarshal(m, b)
+}
+func (m *StopRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StopRequest.Marshal(b, m, deterministic)
+}
+func (m *StopRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StopRequest.Merge(m, src)
+}
+func (m *StopRequest) XXX_Size() int {
+	return xxx_messageInfo_StopRequest.Size(m)
+}
+func (m *StopRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StopRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StopRequest proto.InternalMessageInfo
+
+func (m *StopRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+type StopResponse struct {
+	Running              bool     `protobuf:"varint,1,opt,name=running,proto3" json:"running,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StopResponse) Reset()         { *m = StopResponse{} }
+func (m *StopResponse) String() string { return proto.CompactTextString(m) }
+func (*StopResponse) ProtoMessage()    {}
+func (*StopResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ce5d8dd45b4a91ff, []int{4}
+}
+
+func (m *StopResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StopResponse.Unmarshal(m, b)
+}
+func (m *StopResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StopResponse.Marshal(b, m, deterministic)
+}
+func (m *StopResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StopResponse.Merge(m, src)
+}
+func (m *StopResponse) XXX_Size() int {
+	return xxx_messageInfo_StopResponse.Size(m)
+}
+func (m *StopResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_StopResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StopResponse proto.InternalMessageInfo
+
+func (m *StopResponse) GetRunning() bool {
+	if m != nil {
+		return m.Running
+	}
+	return false
+}
+
+type StatusRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StatusRequest) Reset()         { *m = StatusRequest{} }
+func (m *StatusRequest) String() string { return proto.CompactTextString(m) }
+func (*StatusRequest) ProtoMessage()    {}
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ce5d8dd45b4a91ff, []int{5}
+}
+
+func (m *StatusRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StatusRequest.Unmarshal(m, b)
+}
+func (m *StatusRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StatusRequest.Marshal(b, m, deterministic)
+}
+func (m *StatusRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatusRequest.Merge(m, src)
+}
+func (m *StatusRequest) XXX_Size() int {
+	return xxx_messageInfo_StatusRequest.Size(m)
+}
+func (m *StatusRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatusRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StatusRequest proto.InternalMessageInfo
+
+func (m *StatusRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+type StatusResponse struct {
+	Found                bool     `protobuf:"varint,1,opt,name=found,proto3" json:"found,omitempty"`
+	Running              bool     `protobuf:"varint,2,opt,name=running,proto3" json:"running,omitempty"`
+	OutputSize           int64    `protobuf:"varint,3,opt,name=output_size,json=outputSize,proto3" json:"output_size,omitempty"`
+	Queued               bool     `protobuf:"varint,4,opt,name=queued,proto3" json:"queued,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StatusResponse) Reset()         { *m = StatusResponse{} }
+func (m *StatusResponse) String() string { return proto.CompactTextString(m) }
+func (*StatusResponse) ProtoMessage()    {}
+func (*StatusResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ce5d8dd45b4a91ff, []int{6}
+}
+
+func (m *StatusResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StatusResponse.Unmarshal(m, b)
+}
+func (m *StatusResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StatusResponse.Marshal(b, m, deterministic)
+}
+func (m *StatusResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatusResponse.Merge(m, src)
+}
+func (m *StatusResponse) XXX_Size() int {
+	return xxx_messageInfo_StatusResponse.Size(m)
+}
+func (m *StatusResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatusResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StatusResponse proto.InternalMessageInfo
+
+func (m *StatusResponse) GetFound() bool {
+	if m != nil {
+		return m.Found
+	}
+	return false
+}
+
+func (m *StatusResponse) GetRunning() bool {
+	if m != nil {
+		return m.Running
+	}
+	return false
+}
+
+func (m *StatusResponse) GetOutputSize() int64 {
+	if m != nil {
+		return m.OutputSize
+	}
+	return 0
+}
+
+func (m *StatusResponse) GetQueued() bool {
+	if m != nil {
+		return m.Queued
+	}
+	return false
+}
+
+type AttachRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Offset               int64    `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AttachRequest) Reset()         { *m = AttachRequest{} }
+func (m *AttachRequest) String() string { return proto.CompactTextString(m) }
+func (*AttachRequest) ProtoMessage()    {}
+func (*AttachRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ce5d8dd45b4a91ff, []int{7}
+}
+
+func (m *AttachRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AttachRequest.Unmarshal(m, b)
+}
+func (m *AttachRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AttachRequest.Marshal(b, m, deterministic)
+}
+func (m *AttachRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AttachRequest.Merge(m, src)
+}
+func (m *AttachRequest) XXX_Size() int {
+	return xxx_messageInfo_AttachRequest.Size(m)
+}
+func (m *AttachRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AttachRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AttachRequest proto.InternalMessageInfo
+
+func (m *AttachRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *AttachRequest) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+type ResultRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResultRequest) Reset()         { *m = ResultRequest{} }
+func (m *ResultRequest) String() string { return proto.CompactTextString(m) }
+func (*ResultRequest) ProtoMessage()    {}
+func (*ResultRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ce5d8dd45b4a91ff, []int{8}
+}
+
+func (m *ResultRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResultRequest.Unmarshal(m, b)
+}
+func (m *ResultRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResultRequest.Marshal(b, m, deterministic)
+}
+func (m *ResultRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResultRequest.Merge(m, src)
+}
+func (m *ResultRequest) XXX_Size() int {
+	return xxx_messageInfo_ResultRequest.Size(m)
+}
+func (m *ResultRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResultRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResultRequest proto.InternalMessageInfo
+
+func (m *ResultRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*TaskRequest)(nil), "rpc.TaskRequest")
+	proto.RegisterMapType((map[string]string)(nil), "rpc.TaskRequest.EnvEntry")
 	proto.RegisterType((*TaskResponse)(nil), "rpc.TaskResponse")
+	proto.RegisterType((*TaskOutput)(nil), "rpc.TaskOutput")
+	proto.RegisterType((*StopRequest)(nil), "rpc.StopRequest")
+	proto.RegisterType((*StopResponse)(nil), "rpc.StopResponse")
+	proto.RegisterType((*StatusRequest)(nil), "rpc.StatusRequest")
+	proto.RegisterType((*StatusResponse)(nil), "rpc.StatusResponse")
+	proto.RegisterType((*AttachRequest)(nil), "rpc.AttachRequest")
+	proto.RegisterType((*ResultRequest)(nil), "rpc.ResultRequest")
+}
+
+func init() { proto.RegisterFile("task.proto", fileDescriptor_ce5d8dd45b4a91ff) }
+
+var fileDescriptor_ce5d8dd45b4a91ff = []byte{
+	// 705 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x54, 0xcb, 0x6e, 0xdb, 0x38,
+	0x14, 0x8d, 0x2c, 0x3f, 0xe4, 0x2b, 0x3b, 0x0f, 0x4e, 0x30, 0x60, 0x3c, 0x08, 0xe2, 0xd1, 0xca,
+	0xc0, 0x4c, 0x8c, 0x89, 0x03, 0x4c, 0x8b, 0xee, 0x8a, 0x36, 0xe8, 0xa6, 0x45, 0x01, 0x39, 0x7b,
+	0x43, 0x95, 0x18, 0x87, 0xb0, 0x25, 0x32, 0x7c, 0xa4, 0x75, 0xb6, 0xfd, 0xa3, 0xfe, 0x4f, 0x7f,
+	0xa0, 0x5f, 0x51, 0xf0, 0x21, 0x43, 0x6e, 0xe3, 0x9d, 0xce, 0xb9, 0xef, 0x73, 0x2f, 0x05, 0xa0,
+	0x32, 0xb9, 0x9a, 0x72, 0xc1, 0x14, 0x43, 0xa1, 0xe0, 0x79, 0xf2, 0x23, 0x84, 0xf8, 0x36, 0x93,
+	0xab, 0x94, 0x3c, 0x68, 0x22, 0x15, 0xc2, 0xd0, 0xcb, 0x59, 0x59, 0x66, 0x55, 0x81, 0x5b, 0xe3,
+	0x60, 0xd2, 0x4f, 0x6b, 0x68, 0x2c, 0x8a, 0x96, 0x84, 0x69, 0x85, 0xc3, 0x71, 0x30, 0xe9, 0xa4,
+	0x35, 0x44, 0x87, 0xd0, 0xa2, 0x05, 0x6e, 0x8f, 0x83, 0x49, 0x98, 0xb6, 0x68, 0x81, 0xfe, 0x81,
+	0x90, 0x54, 0x8f, 0xb8, 0x33, 0x0e, 0x27, 0xf1, 0xec, 0x6c, 0x2a, 0x78, 0x3e, 0x6d, 0x94, 0x98,
+	0xde, 0x54, 0x8f, 0x37, 0x95, 0x12, 0x9b, 0xd4, 0x78, 0xa1, 0x53, 0xe8, 0xc8, 0x7b, 0xb2, 0x5e,
+	0xe3, 0xae, 0x2d, 0xe7, 0x00, 0x3a, 0x83, 0xe8, 0x33, 0x13, 0xab, 0x45, 0x41, 0x05, 0xee, 0xb9,
+	0x3e, 0x0c, 0x7e, 0x4b, 0x05, 0x42, 0xd0, 0xd6, 0x92, 0x08, 0x1c, 0x59, 0xda, 0x7e, 0x9b, 0x24,
+	0x4b, 0xc1, 0x34, 0xc7, 0x7d, 0x97, 0xc4, 0x02, 0xf4, 0x17, 0xf4, 0x73, 0xae, 0x17, 0x6b, 0x5a,
+	0x52, 0x85, 0xc1, 0xf6, 0x1c, 0xe5, 0x5c, 0xbf, 0x37, 0x18, 0xfd, 0x0d, 0x83, 0x92, 0x94, 0x4c,
+	0x6c, 0xbc, 0x3d, 0xb6, 0xf6, 0xd8, 0x71, 0xce, 0xe5, 0x1c, 0x80, 0xd3, 0x42, 0x7a, 0x87, 0x81,
+	0x75, 0xe8, 0x1b, 0xc6, 0x99, 0x2f, 0x20, 0x96, 0x8a, 0xf1, 0x85, 0xa4, 0xcb, 0x2a, 0x5b, 0xe3,
+	0xa1, 0x2d, 0x0d, 0x86, 0x9a, 0x5b, 0xc6, 0xc4, 0x5b, 0x87, 0xa5, 0xc8, 0x72, 0x82, 0x0f, 0x5d,
+	0xbc, 0x61, 0xde, 0x19, 0x02, 0xfd, 0x09, 0x5d, 0x99, 0x0b, 0xca, 0x15, 0x3e, 0xb2, 0xa1, 0x1e,
+	0xa1, 0x31, 0xc4, 0xb4, 0x52, 0x44, 0x70, 0x41, 0x14, 0x11, 0xf8, 0xd8, 0x1a, 0x9b, 0xd4, 0xe8,
+	0x7f, 0x88, 0x6a, 0x11, 0xd1, 0x31, 0x84, 0x2b, 0xb2, 0xc1, 0x81, 0xf5, 0x32, 0x9f, 0x46, 0x8c,
+	0xc7, 0x6c, 0xad, 0x89, 0x5f, 0xa0, 0x03, 0xaf, 0x5a, 0x2f, 0x83, 0xe4, 0x7b, 0x00, 0x03, 0xb7,
+	0x09, 0xc9, 0x59, 0x25, 0x6d, 0x0b, 0x4c, 0x2b, 0xae, 0x95, 0x8f, 0xf7, 0xc8, 0xa4, 0x20, 0x42,
+	0x30, 0x51, 0xa7, 0xb0, 0xc0, 0x36, 0xac, 0x8a, 0xfa, 0x00, 0xfa, 0xa9, 0x47, 0x9e, 0x27, 0x42,
+	0xe0, 0xf6, 0x96, 0x27, 0x42, 0x18, 0xfd, 0xc9, 0x17, 0xaa, 0x16, 0x39, 0x2b, 0x08, 0xee, 0x38,
+	0xfd, 0x0d, 0xf1, 0x86, 0x15, 0x6e, 0x7a, 0x27, 0x5c, 0xd7, 0x07, 0x59, 0x64, 0x54, 0xe5, 0x24,
+	0x5b, 0x2d, 0xdc, 0x22, 0xec, 0xf2, 0xc3, 0x14, 0x0c, 0xf5, 0xc1, 0x32, 0xe6, 0x34, 0xcc, 0x56,
+	0xcd, 0xf1, 0xd9, 0x1b, 0x08, 0xd3, 0x5e, 0xce, 0xf5, 0x2d, 0x2d, 0x49, 0xf2, 0x35, 0x00, 0x30,
+	0xf3, 0x7d, 0x74, 0x53, 0xec, 0x9b, 0xee, 0x12, 0x22, 0xe1, 0x15, 0xb0, 0x03, 0xc6, 0xb3, 0x93,
+	0xc6, 0x91, 0x3a, 0x43, 0xba, 0x75, 0x69, 0x8c, 0x67, 0xc6, 0x8e, 0xb6, 0xe3, 0x99, 0xf4, 0x77,
+	0x77, 0x92, 0x28, 0x7f, 0xfa, 0x1e, 0x25, 0xe7, 0x10, 0xcf, 0x15, 0xe3, 0xf5, 0x8b, 0x72, 0xaf,
+	0x23, 0xa8, 0x5f, 0x47, 0x32, 0x81, 0x81, 0x33, 0xfb, 0xf4, 0x18, 0x7a, 0x42, 0x57, 0x15, 0xad,
+	0x96, 0xd6, 0x29, 0x4a, 0x6b, 0x98, 0x5c, 0xc0, 0x70, 0xae, 0x32, 0xa5, 0xe5, 0xbe, 0x54, 0x1b,
+	0x38, 0xac, 0x1d, 0x7c, 0xb2, 0x53, 0xe8, 0xdc, 0x31, 0x5d, 0x15, 0x3e, 0x95, 0x03, 0xcd, 0x12,
+	0xad, 0x9d, 0x12, 0x46, 0x6d, 0x27, 0xca, 0x42, 0xd2, 0x27, 0x62, 0x07, 0x0c, 0x53, 0x70, 0xd4,
+	0x9c, 0x3e, 0xd9, 0xe1, 0x1f, 0x34, 0xd1, 0xc4, 0xbd, 0xef, 0x28, 0xf5, 0x28, 0x79, 0x01, 0xc3,
+	0xd7, 0x4a, 0x65, 0xf9, 0xfd, 0x9e, 0xde, 0x1a, 0xea, 0xb4, 0x76, 0xd4, 0xb9, 0x80, 0x61, 0x4a,
+	0xa4, 0x5e, 0xab, 0x3d, 0x81, 0xb3, 0x6f, 0x2d, 0x68, 0x9b, 0x4d, 0xa0, 0x7f, 0x21, 0x4c, 0x75,
+	0x85, 0x8e, 0x7f, 0xfd, 0x81, 0x8c, 0x7e, 0xdf, 0x56, 0x72, 0x80, 0x66, 0xd0, 0x4f, 0x75, 0x35,
+	0x57, 0x82, 0x64, 0xe5, 0x33, 0x31, 0x47, 0x5b, 0xc6, 0x1d, 0x47, 0x72, 0xf0, 0x5f, 0x80, 0x2e,
+	0xa1, 0x6d, 0x56, 0xe1, 0xdd, 0x1b, 0x4b, 0x1b, 0x9d, 0x34, 0x98, 0x6d, 0x89, 0x6b, 0xe8, 0x3a,
+	0xb9, 0x11, 0xf2, 0xe6, 0xc6, 0x72, 0x46, 0x7f, 0xec, 0x70, 0xdb, 0xa0, 0x2b, 0xe8, 0x3a, 0xa1,
+	0x7c, 0xd0, 0x8e, 0x6a, 0xcf, 0xb7, 0x75, 0x05, 0x5d, 0x27, 0x91, 0x0f, 0xd9, 0xd1, 0xeb, 0xd9,
+	0xe9, 0x3f, 0x75, 0xed, 0x2f, 0xfd, 0xfa, 0xe7, 0x00, 0xf5, 0xa4, 0x11, 0xfc, 0xe0, 0x05, 0x00,
+	0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -102,10 +669,16 @@ var _ grpc.ClientConn
 // is compatible with the grpc package it is being compiled against.
 const _ = grpc.SupportPackageIsVersion4
 
-// Client API for Task service
-
+// TaskClient is the client API for Task service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type TaskClient interface {
 	Run(ctx context.Context, in *TaskRequest, opts ...grpc.CallOption) (*TaskResponse, error)
+	RunStream(ctx context.Context, in *TaskRequest, opts ...grpc.CallOption) (Task_RunStreamClient, error)
+	Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*StopResponse, error)
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	Attach(ctx context.Context, in *AttachRequest, opts ...grpc.CallOption) (Task_AttachClient, error)
+	Result(ctx context.Context, in *ResultRequest, opts ...grpc.CallOption) (*TaskResponse, error)
 }
 
 type taskClient struct {
@@ -118,17 +691,112 @@ func NewTaskClient(cc *grpc.ClientConn) TaskClient {
 
 func (c *taskClient) Run(ctx context.Context, in *TaskRequest, opts ...grpc.CallOption) (*TaskResponse, error) {
 	out := new(TaskResponse)
-	err := grpc.Invoke(ctx, "/rpc.Task/Run", in, out, c.cc, opts...)
+	err := c.cc.Invoke(ctx, "/rpc.Task/Run", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskClient) RunStream(ctx context.Context, in *TaskRequest, opts ...grpc.CallOption) (Task_RunStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Task_serviceDesc.Streams[0], "/rpc.Task/RunStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &taskRunStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Task_RunStreamClient interface {
+	Recv() (*TaskOutput, error)
+	grpc.ClientStream
+}
+
+type taskRunStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *taskRunStreamClient) Recv() (*TaskOutput, error) {
+	m := new(TaskOutput)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *taskClient) Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*StopResponse, error) {
+	out := new(StopResponse)
+	err := c.cc.Invoke(ctx, "/rpc.Task/Stop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, "/rpc.Task/Status", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-// Server API for Task service
+func (c *taskClient) Attach(ctx context.Context, in *AttachRequest, opts ...grpc.CallOption) (Task_AttachClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Task_serviceDesc.Streams[1], "/rpc.Task/Attach", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &taskAttachClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Task_AttachClient interface {
+	Recv() (*TaskOutput, error)
+	grpc.ClientStream
+}
+
+type taskAttachClient struct {
+	grpc.ClientStream
+}
+
+func (x *taskAttachClient) Recv() (*TaskOutput, error) {
+	m := new(TaskOutput)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *taskClient) Result(ctx context.Context, in *ResultRequest, opts ...grpc.CallOption) (*TaskResponse, error) {
+	out := new(TaskResponse)
+	err := c.cc.Invoke(ctx, "/rpc.Task/Result", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
 
+// TaskServer is the server API for Task service.
 type TaskServer interface {
 	Run(context.Context, *TaskRequest) (*TaskResponse, error)
+	RunStream(*TaskRequest, Task_RunStreamServer) error
+	Stop(context.Context, *StopRequest) (*StopResponse, error)
+	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+	Attach(*AttachRequest, Task_AttachServer) error
+	Result(context.Context, *ResultRequest) (*TaskResponse, error)
 }
 
 func RegisterTaskServer(s *grpc.Server, srv TaskServer) {
@@ -153,6 +821,102 @@ func _Task_Run_Handler(srv interface{}, ctx context.Context, dec func(interface{
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Task_RunStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TaskRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TaskServer).RunStream(m, &taskRunStreamServer{stream})
+}
+
+type Task_RunStreamServer interface {
+	Send(*TaskOutput) error
+	grpc.ServerStream
+}
+
+type taskRunStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *taskRunStreamServer) Send(m *TaskOutput) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Task_Stop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskServer).Stop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.Task/Stop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskServer).Stop(ctx, req.(*StopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Task_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.Task/Status",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Task_Attach_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AttachRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TaskServer).Attach(m, &taskAttachServer{stream})
+}
+
+type Task_AttachServer interface {
+	Send(*TaskOutput) error
+	grpc.ServerStream
+}
+
+type taskAttachServer struct {
+	grpc.ServerStream
+}
+
+func (x *taskAttachServer) Send(m *TaskOutput) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Task_Result_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResultRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskServer).Result(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.Task/Result",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskServer).Result(ctx, req.(*ResultRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Task_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "rpc.Task",
 	HandlerType: (*TaskServer)(nil),
@@ -161,25 +925,30 @@ var _Task_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Run",
 			Handler:    _Task_Run_Handler,
 		},
+		{
+			MethodName: "Stop",
+			Handler:    _Task_Stop_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _Task_Status_Handler,
+		},
+		{
+			MethodName: "Result",
+			Handler:    _Task_Result_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "RunStream",
+			Handler:       _Task_RunStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Attach",
+			Handler:       _Task_Attach_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "task.proto",
 }
-
-func init() { proto.RegisterFile("task.proto", fileDescriptor0) }
-
-var fileDescriptor0 = []byte{
-	// 184 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x8f, 0xcf, 0x8a, 0x83, 0x30,
-	0x10, 0xc6, 0x37, 0x46, 0x5d, 0x76, 0x76, 0x59, 0xda, 0xa1, 0x94, 0xd0, 0x93, 0x78, 0xf2, 0x50,
-	0x3c, 0xb4, 0x3d, 0xf6, 0x25, 0x1a, 0xfa, 0x02, 0x56, 0x73, 0x10, 0xd1, 0x49, 0x93, 0xc9, 0xfb,
-	0x17, 0xff, 0x81, 0xc7, 0xdf, 0x0c, 0xf3, 0xfd, 0xe6, 0x03, 0xe0, 0xca, 0x77, 0xa5, 0x75, 0xc4,
-	0x84, 0xd2, 0xd9, 0x3a, 0x7f, 0xc0, 0xef, 0xb3, 0xf2, 0x9d, 0x36, 0xef, 0x60, 0x3c, 0xa3, 0x82,
-	0xef, 0x9a, 0xfa, 0xbe, 0x1a, 0x1a, 0x15, 0x65, 0xa2, 0xf8, 0xd1, 0x2b, 0x8e, 0x1b, 0x6e, 0x7b,
-	0x43, 0x81, 0x95, 0xcc, 0x44, 0x91, 0xe8, 0x15, 0xf1, 0x1f, 0xa2, 0xb6, 0x51, 0x71, 0x26, 0x0a,
-	0xa9, 0xa3, 0xb6, 0xc9, 0xef, 0xf0, 0x37, 0x47, 0x7a, 0x4b, 0x83, 0x37, 0x78, 0x84, 0x94, 0x02,
-	0xdb, 0xc0, 0x4a, 0x4c, 0x91, 0x0b, 0xe1, 0x01, 0x12, 0xe3, 0x1c, 0xb9, 0xc5, 0x34, 0xc3, 0xe5,
-	0x06, 0xf1, 0x78, 0x8d, 0x67, 0x90, 0x3a, 0x0c, 0xb8, 0x2b, 0x9d, 0xad, 0xcb, 0xcd, 0x8b, 0xa7,
-	0xfd, 0x66, 0x32, 0x1b, 0xf2, 0xaf, 0x57, 0x3a, 0x55, 0xba, 0x7e, 0x02, 0x00, 0x00, 0xff, 0xff,
-	0xd7, 0x7f, 0x8a, 0x9d, 0xe0, 0x00, 0x00, 0x00,
-}
@@ -0,0 +1,269 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	pb "github.com/ouqiang/gocron/internal/modules/rpc/proto"
+	"github.com/ouqiang/gocron/internal/modules/utils"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 任务在任务节点登记后执行, 流式调用与调度器的连接断开不影响执行, 调度器重新连接后通过Attach获取后续输出及执行结果
+// 非流式调用Run的连接断开时停止任务, 兼容通过取消调用停止任务的旧版本调度器
+// 执行结束后保留执行结果一段时间, 供调度器通过Result获取
+
+// 执行结束后执行结果的保留时间
+const resultRetention = 10 * time.Minute
+
+// 重新连接时补发的输出最大长度, 超出时丢弃最早的输出, 不受MaxOutput影响, 执行结果送达后释放
+const maxRetainOutput = 1024 * 1024
+
+var executions = struct {
+	sync.Mutex
+	items map[int64]*execution // 任务ID => 执行记录
+}{items: make(map[int64]*execution)}
+
+type execution struct {
+	id     int64
+	cancel context.CancelFunc
+	output *utils.ShellOutput // 执行结果中的输出
+	slot   *limitSlot         // 并发限制的执行名额, 不限制时为nil
+
+	mu         sync.Mutex
+	chunks     []outputChunk    // 重新连接时补发的输出
+	retained   int              // chunks的总长度
+	maxRetain  int              // chunks保留的最大长度, 超出时丢弃最早的输出
+	size       int64            // 已产生的输出长度
+	notify     chan struct{}    // 有新输出或执行结束时关闭
+	done       chan struct{}    // 执行结束时关闭
+	resp       *pb.TaskResponse // 执行结果, 不含输出
+	finishedAt time.Time
+}
+
+type outputChunk struct {
+	offset int64
+	data   []byte
+	stderr bool
+}
+
+// 登记并开始执行任务, 同一任务ID正在执行时返回AlreadyExists, 等待队列已满时返回ResourceExhausted
+func (s Server) start(req *pb.TaskRequest) (*execution, error) {
+	executions.Lock()
+	defer executions.Unlock()
+	for id, item := range executions.items {
+		if item.finished() && time.Since(item.finishedAt) > resultRetention {
+			delete(executions.items, id)
+		}
+	}
+	if item, ok := executions.items[req.Id]; ok && !item.finished() {
+		return nil, status.Errorf(codes.AlreadyExists, "task %d is already running", req.Id)
+	}
+	var slot *limitSlot
+	if s.Limiter != nil {
+		var err error
+		slot, err = s.Limiter.acquire(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ctx, cancel := s.withTimeout(context.Background(), req)
+	e := &execution{
+		id:        req.Id,
+		cancel:    cancel,
+		slot:      slot,
+		output:    utils.NewShellOutput(s.MaxOutput),
+		maxRetain: maxRetainOutput,
+		notify:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	executions.items[req.Id] = e
+	go e.run(ctx, req)
+
+	return e, nil
+}
+
+func findExecution(id int64) (*execution, bool) {
+	executions.Lock()
+	defer executions.Unlock()
+	e, ok := executions.items[id]
+
+	return e, ok
+}
+
+func (e *execution) run(ctx context.Context, req *pb.TaskRequest) {
+	defer e.cancel()
+	exitStatus := utils.ExitStatus{ExitCode: -1}
+	err := e.slot.wait(ctx)
+	if err == nil {
+		exitStatus, err = utils.ExecShellStream(ctx, shellCommand(req), executionWriter{e, false}, executionWriter{e, true})
+		e.slot.done()
+	}
+	resp := newTaskResponse(exitStatus, err)
+	log.Infof("execute cmd end: [id: %d cmd: %s err: %s]", req.Id, req.Command, resp.Error)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.resp = resp
+	e.finishedAt = time.Now()
+	e.broadcast()
+	close(e.done)
+}
+
+func (e *execution) write(p []byte, stderr bool) {
+	if stderr {
+		e.output.StderrWriter().Write(p)
+	} else {
+		e.output.StdoutWriter().Write(p)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	data := make([]byte, len(p))
+	copy(data, p)
+	e.chunks = append(e.chunks, outputChunk{offset: e.size, data: data, stderr: stderr})
+	e.size += int64(len(p))
+	e.retained += len(p)
+	for e.retained > e.maxRetain && len(e.chunks) > 1 {
+		e.retained -= len(e.chunks[0].data)
+		e.chunks = e.chunks[1:]
+	}
+	e.broadcast()
+}
+
+func (e *execution) broadcast() {
+	close(e.notify)
+	e.notify = make(chan struct{})
+}
+
+func (e *execution) finished() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.resp != nil
+}
+
+// 偏移量之后的输出, 执行结束时返回执行结果, 否则返回有新输出时关闭的通道
+// 偏移量之前的输出已丢弃时从保留的最早输出开始返回
+func (e *execution) read(offset int64) ([]outputChunk, *pb.TaskResponse, <-chan struct{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	chunks := make([]outputChunk, 0)
+	for _, chunk := range e.chunks {
+		end := chunk.offset + int64(len(chunk.data))
+		if end <= offset {
+			continue
+		}
+		if chunk.offset < offset {
+			chunk.data = chunk.data[offset-chunk.offset:]
+			chunk.offset = offset
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, e.resp, e.notify
+}
+
+// 执行结果, withOutput为true时包含输出, 补发的输出不再需要, 释放
+func (e *execution) result(withOutput bool) *pb.TaskResponse {
+	e.mu.Lock()
+	resp := *e.resp
+	e.mu.Unlock()
+	if withOutput {
+		e.release()
+		resp.Output = e.output.Combined()
+		resp.Stdout = e.output.Stdout()
+		resp.Stderr = e.output.Stderr()
+	}
+
+	return &resp
+}
+
+// 执行结果已送达, 释放补发的输出
+func (e *execution) release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.chunks = nil
+	e.retained = 0
+}
+
+func (e *execution) status() *pb.StatusResponse {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return &pb.StatusResponse{Found: true, Running: e.resp == nil, OutputSize: e.size, Queued: e.slot.queued()}
+}
+
+// 等待执行结束
+func (e *execution) wait(ctx context.Context) error {
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 等待执行中的任务结束, 任务节点退出前调用
+func waitExecutions() {
+	executions.Lock()
+	running := make([]*execution, 0)
+	for _, e := range executions.items {
+		if !e.finished() {
+			running = append(running, e)
+		}
+	}
+	executions.Unlock()
+	if len(running) > 0 {
+		log.Infof("waiting for %d running tasks", len(running))
+	}
+	for _, e := range running {
+		<-e.done
+	}
+}
+
+type outputStream interface {
+	Send(*pb.TaskOutput) error
+	Context() context.Context
+}
+
+// 从偏移量开始发送输出, 执行结束后发送执行结果, 连接断开时任务继续执行
+func (e *execution) attach(offset int64, stream outputStream) error {
+	for {
+		chunks, resp, notify := e.read(offset)
+		for _, chunk := range chunks {
+			err := stream.Send(&pb.TaskOutput{Output: string(chunk.data), Stderr: chunk.stderr, Offset: chunk.offset})
+			if err != nil {
+				log.Warnf("send output failed, task keeps running: [id: %d err: %s]", e.id, err)
+				return err
+			}
+			offset = chunk.offset + int64(len(chunk.data))
+		}
+		if resp != nil {
+			err := stream.Send(&pb.TaskOutput{Response: e.result(false)})
+			if err == nil {
+				e.release()
+			}
+			return err
+		}
+		select {
+		case <-notify:
+		case <-stream.Context().Done():
+			log.Warnf("client disconnected, task keeps running: [id: %d]", e.id)
+			return stream.Context().Err()
+		}
+	}
+}
+
+type executionWriter struct {
+	execution *execution
+	stderr    bool
+}
+
+func (w executionWriter) Write(p []byte) (int, error) {
+	w.execution.write(p, w.stderr)
+
+	return len(p), nil
+}
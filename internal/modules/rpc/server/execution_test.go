@@ -0,0 +1,39 @@
+package server
+
+import (
+	"testing"
+
+	pb "github.com/ouqiang/gocron/internal/modules/rpc/proto"
+	"github.com/ouqiang/gocron/internal/modules/utils"
+)
+
+func TestExecutionRead(t *testing.T) {
+	e := &execution{output: utils.NewShellOutput(0), maxRetain: maxRetainOutput, notify: make(chan struct{})}
+	e.write([]byte("abc"), false)
+	e.write([]byte("def"), true)
+	e.write([]byte("ghi"), false)
+
+	chunks, resp, _ := e.read(4)
+	if resp != nil || len(chunks) != 2 || chunks[0].offset != 4 || string(chunks[0].data) != "ef" || !chunks[0].stderr {
+		t.Fatalf("从偏移量4读取错误, 实际%+v", chunks)
+	}
+	if chunks, _, _ = e.read(9); len(chunks) != 0 {
+		t.Fatalf("已读取全部输出时应返回空, 实际%+v", chunks)
+	}
+
+	// 超出保留长度时丢弃最早的输出
+	e = &execution{output: utils.NewShellOutput(0), maxRetain: 7, notify: make(chan struct{})}
+	e.write([]byte("abc"), false)
+	e.write([]byte("def"), false)
+	e.write([]byte("ghi"), false)
+	chunks, _, _ = e.read(0)
+	if len(chunks) != 2 || chunks[0].offset != 3 || e.size != 9 {
+		t.Fatalf("偏移量之前的输出已丢弃时应从保留的最早输出开始, 实际%+v", chunks)
+	}
+
+	// 执行结果送达后释放补发的输出
+	e.resp = &pb.TaskResponse{}
+	if resp := e.result(true); resp.Output != "abcdefghi" || e.chunks != nil || e.retained != 0 {
+		t.Fatalf("获取执行结果后应释放补发的输出, 实际%+v", e.chunks)
+	}
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"errors"
+	"regexp"
+	"sync"
+
+	pb "github.com/ouqiang/gocron/internal/modules/rpc/proto"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 任务节点并发限制, 无法立即执行的任务进入等待队列, 按到达顺序执行
+// 匹配并发规则的任务还需满足规则的并发数, 规则已满时排在后面的其他任务可先执行
+// 排队时间计入任务超时时间, 排队期间可手动停止
+
+// 并发规则, 命令或脚本内容匹配Pattern的任务最多同时执行Max个
+type LimitRule struct {
+	Pattern *regexp.Regexp
+	Max     int
+}
+
+type Limiter struct {
+	maxRunning int // 最大同时执行数, 0不限制
+	maxQueue   int // 等待队列最大长度, 队列已满时拒绝执行
+	rules      []LimitRule
+
+	mu          sync.Mutex
+	running     int
+	ruleRunning []int
+	queue       []*limitSlot
+}
+
+// 执行名额
+type limitSlot struct {
+	limiter *Limiter
+	rules   []int         // 匹配的规则
+	ready   chan struct{} // 可以执行时关闭
+	granted bool
+}
+
+func NewLimiter(maxRunning, maxQueue int, rules []LimitRule) *Limiter {
+	return &Limiter{
+		maxRunning:  maxRunning,
+		maxQueue:    maxQueue,
+		rules:       rules,
+		ruleRunning: make([]int, len(rules)),
+	}
+}
+
+// 申请执行名额, 无法立即执行且等待队列已满时返回ResourceExhausted
+func (l *Limiter) acquire(req *pb.TaskRequest) (*limitSlot, error) {
+	slot := &limitSlot{limiter: l, ready: make(chan struct{})}
+	for i, rule := range l.rules {
+		if rule.Pattern.MatchString(req.Command) || (req.Script != "" && rule.Pattern.MatchString(req.Script)) {
+			slot.rules = append(slot.rules, i)
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.available(slot) {
+		l.grant(slot)
+		return slot, nil
+	}
+	if len(l.queue) >= l.maxQueue {
+		log.Warnf("task queue is full: [id: %d running: %d queued: %d]", req.Id, l.running, len(l.queue))
+		return nil, status.Errorf(codes.ResourceExhausted, "task queue is full, %d running, %d queued", l.running, len(l.queue))
+	}
+	l.queue = append(l.queue, slot)
+	log.Infof("task queued: [id: %d running: %d queued: %d]", req.Id, l.running, len(l.queue))
+
+	return slot, nil
+}
+
+func (l *Limiter) available(slot *limitSlot) bool {
+	if l.maxRunning > 0 && l.running >= l.maxRunning {
+		return false
+	}
+	for _, i := range slot.rules {
+		if l.ruleRunning[i] >= l.rules[i].Max {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (l *Limiter) grant(slot *limitSlot) {
+	l.running++
+	for _, i := range slot.rules {
+		l.ruleRunning[i]++
+	}
+	slot.granted = true
+	close(slot.ready)
+}
+
+// 归还名额, 按顺序唤醒可以执行的任务
+func (l *Limiter) release(slot *limitSlot) {
+	l.running--
+	for _, i := range slot.rules {
+		l.ruleRunning[i]--
+	}
+	queue := make([]*limitSlot, 0, len(l.queue))
+	for _, item := range l.queue {
+		if l.available(item) {
+			l.grant(item)
+		} else {
+			queue = append(queue, item)
+		}
+	}
+	l.queue = queue
+}
+
+// 等待可以执行, ctx结束时退出等待队列
+func (s *limitSlot) wait(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	select {
+	case <-s.ready:
+		return nil
+	case <-ctx.Done():
+	}
+	l := s.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s.granted {
+		l.release(s)
+	} else {
+		for i, item := range l.queue {
+			if item == s {
+				l.queue = append(l.queue[:i], l.queue[i+1:]...)
+				break
+			}
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.New("timeout while waiting in queue")
+	}
+
+	return errors.New("manually stopped while waiting in queue")
+}
+
+// 执行结束后归还名额
+func (s *limitSlot) done() {
+	if s == nil {
+		return
+	}
+	s.limiter.mu.Lock()
+	defer s.limiter.mu.Unlock()
+	s.limiter.release(s)
+}
+
+func (s *limitSlot) queued() bool {
+	if s == nil {
+		return false
+	}
+	s.limiter.mu.Lock()
+	defer s.limiter.mu.Unlock()
+
+	return !s.granted
+}
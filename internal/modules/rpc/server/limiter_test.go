@@ -0,0 +1,59 @@
+package server
+
+import (
+	"regexp"
+	"testing"
+
+	pb "github.com/ouqiang/gocron/internal/modules/rpc/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLimiter(t *testing.T) {
+	limiter := NewLimiter(2, 2, []LimitRule{{Pattern: regexp.MustCompile(`^php\s`), Max: 1}})
+	acquire := func(command string) *limitSlot {
+		slot, err := limiter.acquire(&pb.TaskRequest{Command: command})
+		if err != nil {
+			t.Fatalf("%s 申请执行名额失败: %s", command, err)
+		}
+		return slot
+	}
+	php1 := acquire("php artisan a")
+	php2 := acquire("php artisan b")
+	echo1 := acquire("echo 1")
+	echo2 := acquire("echo 2")
+	if php1.queued() || echo1.queued() || !php2.queued() || !echo2.queued() {
+		t.Fatal("php2因规则并发数已满排队, echo2因最大并发数已满排队")
+	}
+	_, err := limiter.acquire(&pb.TaskRequest{Command: "echo 3"})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("等待队列已满时应返回ResourceExhausted, 实际%v", err)
+	}
+
+	// php规则仍已满, 排在后面的echo2先执行
+	echo1.done()
+	if !php2.queued() || echo2.queued() {
+		t.Fatal("echo1结束后应执行echo2")
+	}
+	php1.done()
+	if php2.queued() {
+		t.Fatal("php1结束后应执行php2")
+	}
+
+	// 排队期间停止, 退出等待队列
+	echo3 := acquire("echo 3")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := echo3.wait(ctx); err == nil {
+		t.Fatal("排队期间停止应返回错误")
+	}
+	if len(limiter.queue) != 0 {
+		t.Fatalf("停止后应退出等待队列, 实际队列长度%d", len(limiter.queue))
+	}
+	php2.done()
+	echo2.done()
+	if limiter.running != 0 || limiter.ruleRunning[0] != 0 {
+		t.Fatalf("执行结束后名额应全部归还, 实际执行中%d", limiter.running)
+	}
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	pb "github.com/ouqiang/gocron/internal/modules/rpc/proto"
+	"gopkg.in/ini.v1"
+)
+
+// 任务节点执行策略, 限制调度器可下发的命令
+// 配置了allow或script_dir时, 命令需匹配其中一项; deny优先于allow
+// 加载策略后, 任务指定的解释器需在shell中配置, 任务环境变量需在env中配置, GOCRON_开头的执行上下文变量除外
+// 脚本任务的内容无法按allow检查, 需配置script = true才允许执行, 指定的解释器需在interpreter中配置
+type Policy struct {
+	Allow        []*regexp.Regexp // 允许的命令, 正则表达式
+	Deny         []*regexp.Regexp // 禁止的命令, 正则表达式, 同时检查脚本内容
+	ScriptDirs   []string         // 允许执行的脚本目录, 命令为目录下可执行文件的绝对路径
+	Shells       []string         // 允许任务指定的解释器
+	Script       bool             // 是否允许脚本任务
+	Interpreters []string         // 允许脚本任务指定的解释器
+	Envs         []string         // 允许任务设置的环境变量名
+	MaxTimeout   int32            // 最大执行时间(秒), 0不限制
+	Limits       []LimitRule      // 并发规则
+}
+
+// 可改变shell、动态链接器行为的环境变量, 可绕过命令检查, 配置在env中也不允许
+var deniedEnvPattern = regexp.MustCompile(`^(LD_|DYLD_|BASH_FUNC_)|^(BASH_ENV|ENV|SHELLOPTS|BASHOPTS|PS4|IFS|PATH)$`)
+
+// 使用脚本目录匹配时, 命令中不能包含的shell控制字符
+func shellControlChars() string {
+	if runtime.GOOS == "windows" {
+		return "&|<>^%\n\r"
+	}
+
+	return ";&|<>$`\\\n\r"
+}
+
+// 从ini文件加载策略, allow、deny、script_dir、shell、interpreter、env、limit可重复配置多项
+func LoadPolicy(filename string) (*Policy, error) {
+	file, err := ini.LoadSources(ini.LoadOptions{
+		AllowShadows:        true,
+		IgnoreInlineComment: true,
+	}, filename)
+	if err != nil {
+		return nil, err
+	}
+	section := file.Section("")
+	policy := new(Policy)
+	policy.Allow, err = compilePatterns(section.Key("allow").ValueWithShadows())
+	if err != nil {
+		return nil, err
+	}
+	policy.Deny, err = compilePatterns(section.Key("deny").ValueWithShadows())
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range section.Key("script_dir").ValueWithShadows() {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		if !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("script_dir must be an absolute path: %s", dir)
+		}
+		// 脚本路径解析符号链接后再比较, 目录也需解析
+		realDir, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return nil, err
+		}
+		policy.ScriptDirs = append(policy.ScriptDirs, realDir)
+	}
+	policy.Limits, err = parseLimitRules(section.Key("limit").ValueWithShadows())
+	if err != nil {
+		return nil, err
+	}
+	policy.Shells = normalizeCommands(section.Key("shell").ValueWithShadows())
+	policy.Interpreters = normalizeCommands(section.Key("interpreter").ValueWithShadows())
+	policy.Envs = normalizeCommands(section.Key("env").ValueWithShadows())
+	if section.HasKey("script") {
+		policy.Script, err = section.Key("script").Bool()
+		if err != nil {
+			return nil, errors.New("script must be true or false")
+		}
+	}
+	// Key()在配置项不存在时会创建, 需先判断
+	if section.HasKey("max_timeout") {
+		maxTimeout, err := section.Key("max_timeout").Int()
+		if err != nil || maxTimeout < 0 {
+			return nil, errors.New("max_timeout must be a non-negative integer")
+		}
+		policy.MaxTimeout = int32(maxTimeout)
+	}
+
+	return policy, nil
+}
+
+// 并发规则格式为 最大并发数 正则表达式, 如 2 ^php\s
+func parseLimitRules(values []string) ([]LimitRule, error) {
+	rules := make([]LimitRule, 0)
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		fields := strings.SplitN(value, " ", 2)
+		max, err := strconv.Atoi(fields[0])
+		if err != nil || max < 1 || len(fields) < 2 || strings.TrimSpace(fields[1]) == "" {
+			return nil, fmt.Errorf("invalid limit %s, format: max pattern", value)
+		}
+		pattern, err := regexp.Compile(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %s", fields[1], err)
+		}
+		rules = append(rules, LimitRule{Pattern: pattern, Max: max})
+	}
+
+	return rules, nil
+}
+
+// 合并多余的空白, 忽略空值
+func normalizeCommands(values []string) []string {
+	commands := make([]string, 0)
+	for _, value := range values {
+		value = strings.Join(strings.Fields(value), " ")
+		if value != "" {
+			commands = append(commands, value)
+		}
+	}
+
+	return commands
+}
+
+func compilePatterns(values []string) ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0)
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %s", value, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, nil
+}
+
+// 检查任务是否允许执行
+func (p *Policy) Check(req *pb.TaskRequest) error {
+	for _, pattern := range p.Deny {
+		if pattern.MatchString(req.Command) {
+			return fmt.Errorf("command matches deny pattern %s", pattern)
+		}
+		if req.Script != "" && pattern.MatchString(req.Script) {
+			return fmt.Errorf("script matches deny pattern %s", pattern)
+		}
+	}
+	for key := range req.Env {
+		if deniedEnvPattern.MatchString(key) {
+			return fmt.Errorf("env %s is not allowed", key)
+		}
+		if !strings.HasPrefix(key, "GOCRON_") && !contains(p.Envs, key) {
+			return fmt.Errorf("env %s is not in the allow list", key)
+		}
+	}
+	if req.Script != "" {
+		return p.checkScript(req)
+	}
+	shell := strings.Join(strings.Fields(req.Shell), " ")
+	if shell != "" && !contains(p.Shells, shell) {
+		return fmt.Errorf("shell %s is not allowed", req.Shell)
+	}
+	if len(p.Allow) == 0 && len(p.ScriptDirs) == 0 {
+		return nil
+	}
+	for _, pattern := range p.Allow {
+		if pattern.MatchString(req.Command) {
+			return nil
+		}
+	}
+	if p.inScriptDir(req.Command) {
+		return nil
+	}
+
+	return errors.New("command is not in the allow list")
+}
+
+// 脚本任务的参数不经过shell, 不检查allow
+func (p *Policy) checkScript(req *pb.TaskRequest) error {
+	if !p.Script {
+		return errors.New("script is not allowed")
+	}
+	interpreter := strings.Join(strings.Fields(req.Interpreter), " ")
+	if interpreter != "" && !contains(p.Interpreters, interpreter) {
+		return fmt.Errorf("interpreter %s is not allowed", req.Interpreter)
+	}
+
+	return nil
+}
+
+func contains(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 命令是否为脚本目录下的可执行文件, 可带参数
+func (p *Policy) inScriptDir(command string) bool {
+	if len(p.ScriptDirs) == 0 || strings.ContainsAny(command, shellControlChars()) {
+		return false
+	}
+	fields := strings.Fields(command)
+	if len(fields) == 0 || !filepath.IsAbs(fields[0]) {
+		return false
+	}
+	script, err := filepath.EvalSymlinks(fields[0])
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(script)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	for _, dir := range p.ScriptDirs {
+		if strings.HasPrefix(script, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 任务执行时间不能超过最大执行时间, 超出时按最大执行时间执行
+func (p *Policy) timeout(timeout int32) int32 {
+	if p.MaxTimeout > 0 && (timeout <= 0 || timeout > p.MaxTimeout) {
+		return p.MaxTimeout
+	}
+
+	return timeout
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/ouqiang/gocron/internal/modules/rpc/proto"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocron-policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	scriptDir := filepath.Join(dir, "scripts")
+	os.Mkdir(scriptDir, 0755)
+	ioutil.WriteFile(filepath.Join(scriptDir, "backup.sh"), []byte("#!/bin/sh\n"), 0755)
+	// 指向脚本目录外的符号链接
+	os.Symlink("/bin/sh", filepath.Join(scriptDir, "sh"))
+
+	policyFile := filepath.Join(dir, "policy.ini")
+	content := "allow = ^php /var/www/artisan schedule:run$\n" +
+		"allow = ^echo\\s\n" +
+		"deny = rm\\s+-rf\n" +
+		"script_dir = " + scriptDir + "\n" +
+		"shell = python3   -c\n" +
+		"interpreter = python3\n" +
+		"env = APP_ENV\n" +
+		"env = LD_PRELOAD\n" +
+		"limit = 2 ^php\\s\n" +
+		"max_timeout = 60\n"
+	ioutil.WriteFile(policyFile, []byte(content), 0644)
+	policy, err := LoadPolicy(policyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(policy.Limits) != 1 || policy.Limits[0].Max != 2 || !policy.Limits[0].Pattern.MatchString("php artisan") {
+		t.Fatalf("并发规则不匹配, 实际%+v", policy.Limits)
+	}
+
+	tests := []struct {
+		req     pb.TaskRequest
+		allowed bool
+	}{
+		{pb.TaskRequest{Command: "php /var/www/artisan schedule:run"}, true},
+		{pb.TaskRequest{Command: "php /var/www/artisan schedule:run; id"}, false},
+		{pb.TaskRequest{Command: "echo hello"}, true},
+		{pb.TaskRequest{Command: "echo x && rm -rf /tmp/x"}, false},
+		{pb.TaskRequest{Command: filepath.Join(scriptDir, "backup.sh") + " --full"}, true},
+		{pb.TaskRequest{Command: filepath.Join(scriptDir, "backup.sh") + "; id"}, false},
+		{pb.TaskRequest{Command: filepath.Join(scriptDir, "..", "scripts", "backup.sh")}, true},
+		{pb.TaskRequest{Command: filepath.Join(scriptDir, "sh") + " -c id"}, false},
+		{pb.TaskRequest{Command: "cat /etc/passwd"}, false},
+		{pb.TaskRequest{Command: "echo hello", Shell: "python3 -c"}, true},
+		{pb.TaskRequest{Command: "echo hello", Shell: "sh -c id"}, false},
+		{pb.TaskRequest{Command: "echo hello", Env: map[string]string{"BASH_ENV": "/tmp/x"}}, false},
+		{pb.TaskRequest{Command: "echo hello", Env: map[string]string{"APP_ENV": "prod", "GOCRON_TASK_ID": "1"}}, true},
+		{pb.TaskRequest{Command: "echo hello", Env: map[string]string{"PYTHONPATH": "/tmp"}}, false},
+		{pb.TaskRequest{Command: "echo hello", Env: map[string]string{"LD_PRELOAD": "/tmp/x.so"}}, false},
+		{pb.TaskRequest{Script: "echo hello\n"}, false},
+	}
+	for _, item := range tests {
+		err := policy.Check(&item.req)
+		if (err == nil) != item.allowed {
+			t.Errorf("%s shell: %s env: %v 期望允许执行: %v, 实际: %v", item.req.Command, item.req.Shell, item.req.Env, item.allowed, err)
+		}
+	}
+
+	policy.Script = true
+	scriptTests := []struct {
+		req     pb.TaskRequest
+		allowed bool
+	}{
+		{pb.TaskRequest{Script: "id\nwhoami\n", Command: "--verbose"}, true},
+		{pb.TaskRequest{Script: "print(1)\n", Interpreter: "python3"}, true},
+		{pb.TaskRequest{Script: "print 1;\n", Interpreter: "perl"}, false},
+		{pb.TaskRequest{Script: "cd /tmp\nrm  -rf x\n"}, false},
+		{pb.TaskRequest{Script: "id\n", Env: map[string]string{"LD_PRELOAD": "/tmp/x.so"}}, false},
+	}
+	for _, item := range scriptTests {
+		err := policy.Check(&item.req)
+		if (err == nil) != item.allowed {
+			t.Errorf("脚本 %q interpreter: %s 期望允许执行: %v, 实际: %v", item.req.Script, item.req.Interpreter, item.allowed, err)
+		}
+	}
+
+	for timeout, expected := range map[int32]int32{0: 60, 30: 30, 120: 60} {
+		if actual := policy.timeout(timeout); actual != expected {
+			t.Errorf("超时时间%d, 期望%d, 实际%d", timeout, expected, actual)
+		}
+	}
+
+	// 未配置的可选项使用默认值
+	ioutil.WriteFile(policyFile, []byte("deny = rm\\s+-rf\n"), 0644)
+	policy, err = LoadPolicy(policyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.MaxTimeout != 0 || policy.Script {
+		t.Errorf("默认策略不匹配, 实际%+v", policy)
+	}
+}
@@ -13,11 +13,19 @@ import (
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
 )
 
-type Server struct{}
+type Server struct {
+	AllowUsers  []string // 允许任务指定的执行用户
+	AllowGroups []string // 允许任务指定的执行用户组
+	Policy      *Policy  // 执行策略, 为nil时不限制
+	MaxOutput   int      // 非流式调用时返回的输出最大长度(字节), 超出时保留开头和末尾部分, 0不限制
+	Limiter     *Limiter // 并发限制, 为nil时不限制
+}
 
 var keepAlivePolicy = keepalive.EnforcementPolicy{
 	MinTime:             10 * time.Second,
@@ -37,20 +45,176 @@ func (s Server) Run(ctx context.Context, req *pb.TaskRequest) (*pb.TaskResponse,
 		}
 	}()
 	log.Infof("execute cmd start: [id: %d cmd: %s]", req.Id, req.Command)
-	output, err := utils.ExecShell(ctx, req.Command)
+	if err := s.check(req); err != nil {
+		return nil, err
+	}
+	e, err := s.start(req)
+	if err != nil {
+		return nil, err
+	}
+	err = e.wait(ctx)
+	if err != nil {
+		// 旧版本调度器通过取消Run调用停止任务, 非流式调用的连接断开时停止任务
+		log.Warnf("client disconnected, stop task: [id: %d]", req.Id)
+		e.cancel()
+		return nil, err
+	}
+
+	return e.result(true), nil
+}
+
+func (s Server) RunStream(req *pb.TaskRequest, stream pb.Task_RunStreamServer) error {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error(err)
+		}
+	}()
+	log.Infof("execute cmd start: [id: %d cmd: %s]", req.Id, req.Command)
+	if err := s.check(req); err != nil {
+		return err
+	}
+	e, err := s.start(req)
+	if err != nil {
+		return err
+	}
+
+	return e.attach(0, stream)
+}
+
+// 手动停止任务, 按任务设置的停止信号结束进程
+func (s Server) Stop(ctx context.Context, req *pb.StopRequest) (*pb.StopResponse, error) {
+	e, ok := findExecution(req.Id)
+	running := ok && !e.finished()
+	if running {
+		log.Infof("stop task: [id: %d]", req.Id)
+		e.cancel()
+	}
+
+	return &pb.StopResponse{Running: running}, nil
+}
+
+func (s Server) Status(ctx context.Context, req *pb.StatusRequest) (*pb.StatusResponse, error) {
+	e, ok := findExecution(req.Id)
+	if !ok {
+		return &pb.StatusResponse{}, nil
+	}
+
+	return e.status(), nil
+}
+
+func (s Server) Attach(req *pb.AttachRequest, stream pb.Task_AttachServer) error {
+	e, ok := findExecution(req.Id)
+	if !ok {
+		return status.Errorf(codes.NotFound, "task %d not found", req.Id)
+	}
+	log.Infof("client reattached: [id: %d offset: %d]", req.Id, req.Offset)
+
+	return e.attach(req.Offset, stream)
+}
+
+func (s Server) Result(ctx context.Context, req *pb.ResultRequest) (*pb.TaskResponse, error) {
+	e, ok := findExecution(req.Id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "task %d not found", req.Id)
+	}
+	if !e.finished() {
+		return nil, status.Errorf(codes.FailedPrecondition, "task %d is still running", req.Id)
+	}
+
+	return e.result(true), nil
+}
+
+// 检查执行策略及执行用户, 拒绝执行时返回PermissionDenied, 停止信号无效时返回InvalidArgument
+func (s Server) check(req *pb.TaskRequest) error {
+	if !utils.ValidStopSignal(req.StopSignal) {
+		log.Warnf("unknown stop signal: [id: %d signal: %s]", req.Id, req.StopSignal)
+		return status.Errorf(codes.InvalidArgument, "unknown stop signal %s", req.StopSignal)
+	}
+	if req.Script != "" {
+		log.Infof("execute script: [id: %d interpreter: %s sha256: %s]", req.Id, req.Interpreter, utils.Sha256(req.Script))
+	}
+	if s.Policy != nil {
+		if err := s.Policy.Check(req); err != nil {
+			log.Warnf("execute cmd denied: [id: %d cmd: %s err: %s]", req.Id, req.Command, err)
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+
+	return s.checkRunAs(req)
+}
+
+// 超时由任务节点控制, 超时后按停止信号结束进程, 执行策略限制了最大执行时间时取较小值
+func (s Server) withTimeout(ctx context.Context, req *pb.TaskRequest) (context.Context, context.CancelFunc) {
+	timeout := req.Timeout
+	if s.Policy != nil {
+		timeout = s.Policy.timeout(timeout)
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+}
+
+// 任务指定的执行用户、用户组需在允许列表中
+func (s Server) checkRunAs(req *pb.TaskRequest) error {
+	if req.User == "" && req.Group == "" {
+		return nil
+	}
+	if req.User == "" {
+		return status.Error(codes.PermissionDenied, "run as group requires a user")
+	}
+	if !utils.InStringSlice(s.AllowUsers, req.User) {
+		log.Warnf("run as user denied: [id: %d user: %s]", req.Id, req.User)
+		return status.Errorf(codes.PermissionDenied, "user %s is not allowed on this node", req.User)
+	}
+	if req.Group != "" && !utils.InStringSlice(s.AllowGroups, req.Group) {
+		log.Warnf("run as group denied: [id: %d group: %s]", req.Id, req.Group)
+		return status.Errorf(codes.PermissionDenied, "group %s is not allowed on this node", req.Group)
+	}
+
+	return nil
+}
+
+func shellCommand(req *pb.TaskRequest) utils.ShellCommand {
+	env := make([]string, 0, len(req.Env))
+	for key, value := range req.Env {
+		env = append(env, key+"="+value)
+	}
+
+	return utils.ShellCommand{
+		Command: req.Command,
+		Shell:   req.Shell,
+		Dir:     req.WorkDir,
+		Env:     env,
+		User:    req.User,
+		Group:   req.Group,
+		Limits: utils.ResourceLimits{
+			CPU:    int(req.CpuLimit),
+			Memory: int(req.MemoryLimit),
+			Pids:   int(req.PidsLimit),
+		},
+		StopSignal:  req.StopSignal,
+		StopGrace:   time.Duration(req.StopGrace) * time.Second,
+		Script:      req.Script,
+		Interpreter: req.Interpreter,
+	}
+}
+
+func newTaskResponse(exitStatus utils.ExitStatus, err error) *pb.TaskResponse {
 	resp := new(pb.TaskResponse)
-	resp.Output = output
+	resp.ExitCode = int32(exitStatus.ExitCode)
+	resp.Signal = exitStatus.Signal
+	resp.PeakMemory = exitStatus.PeakMemory
+	resp.CpuTime = int64(exitStatus.CPUTime / time.Millisecond)
 	if err != nil {
 		resp.Error = err.Error()
-	} else {
-		resp.Error = ""
 	}
-	log.Infof("execute cmd end: [id: %d cmd: %s err: %s]", req.Id, req.Command, resp.Error)
 
-	return resp, nil
+	return resp
 }
 
-func Start(addr string, enableTLS bool, certificate auth.Certificate) {
+func Start(addr string, enableTLS bool, certificate auth.Certificate, taskServer Server) {
 	l, err := net.Listen("tcp", addr)
 	if err != nil {
 		log.Fatal(err)
@@ -68,7 +232,7 @@ func Start(addr string, enableTLS bool, certificate auth.Certificate) {
 		opts = append(opts, opt)
 	}
 	server := grpc.NewServer(opts...)
-	pb.RegisterTaskServer(server, Server{})
+	pb.RegisterTaskServer(server, taskServer)
 	log.Infof("server listen on %s", addr)
 
 	go func() {
@@ -89,6 +253,7 @@ func Start(addr string, enableTLS bool, certificate auth.Certificate) {
 		case syscall.SIGINT, syscall.SIGTERM:
 			log.Info("应用准备退出")
 			server.GracefulStop()
+			waitExecutions()
 			return
 		}
 	}
@@ -36,6 +36,25 @@ type Setting struct {
 
 	ConcurrencyQueue int
 	AuthSecret       string
+
+	// 集群模式, 多个实例通过数据库租约选举主节点, 只有主节点调度任务
+	Cluster struct {
+		Enable   bool
+		Instance string // 实例标识, 集群内唯一
+		LeaseTTL int    // 租约有效期(秒)
+	}
+
+	// 任务输出
+	Output struct {
+		MaxSize int  // 任务日志保存的输出最大长度(字节), 超出时保留开头和末尾部分
+		Spill   bool // 输出超出最大长度时, 完整输出压缩保存到日志目录
+	}
+
+	// 任务节点自动注册
+	Agent struct {
+		Token          string // 任务节点注册、心跳使用的令牌, 为空时不允许自动注册
+		OfflineTimeout int    // 超过该时间(秒)未收到心跳时标记为离线
+	}
 }
 
 // 读取配置
@@ -54,6 +73,10 @@ func Read(filename string) (*Setting, error) {
 	s.Db.User = section.Key("db.user").MustString("")
 	s.Db.Password = section.Key("db.password").MustString("")
 	s.Db.Database = section.Key("db.database").MustString("gocron")
+	if s.Db.Engine == "sqlite" {
+		// SQLite数据库文件路径
+		s.Db.Database = section.Key("db.database").MustString("data/gocron.db")
+	}
 	s.Db.Prefix = section.Key("db.prefix").MustString("")
 	s.Db.Charset = section.Key("db.charset").MustString("utf8")
 	s.Db.MaxIdleConns = section.Key("db.max.idle.conns").MustInt(30)
@@ -70,6 +93,27 @@ func Read(filename string) (*Setting, error) {
 		s.AuthSecret = utils.RandAuthToken()
 	}
 
+	s.Cluster.Enable = section.Key("cluster.enable").MustBool(false)
+	s.Cluster.Instance = section.Key("cluster.instance").MustString("")
+	s.Cluster.LeaseTTL = section.Key("cluster.lease.ttl").MustInt(15)
+	if s.Cluster.LeaseTTL < 3 {
+		s.Cluster.LeaseTTL = 3
+	}
+
+	// 单位KB, mediumtext最大16MB
+	s.Output.MaxSize = section.Key("output.max.size").MustInt(1024)
+	if s.Output.MaxSize <= 0 || s.Output.MaxSize > 8192 {
+		s.Output.MaxSize = 8192
+	}
+	s.Output.MaxSize *= 1024
+	s.Output.Spill = section.Key("output.spill").MustBool(false)
+
+	s.Agent.Token = section.Key("agent.token").MustString("")
+	s.Agent.OfflineTimeout = section.Key("agent.offline.timeout").MustInt(90)
+	if s.Agent.OfflineTimeout < 10 {
+		s.Agent.OfflineTimeout = 10
+	}
+
 	s.EnableTLS = section.Key("enable_tls").MustBool(false)
 	s.CAFile = section.Key("ca_file").MustString("")
 	s.CertFile = section.Key("cert_file").MustString("")
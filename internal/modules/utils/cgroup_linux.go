@@ -0,0 +1,300 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// 设置了资源限制的任务进程放入临时cgroup(v2), 限制CPU、内存、进程数, 统计所有子进程的内存峰值、CPU时间
+// cgroup v2中包含进程的cgroup无法为子cgroup启用控制器, 首次执行设置了资源限制的任务时, 任务节点先移入子cgroup gocron-node, 任务cgroup与其同级
+// 使用systemd启动时需配置 Delegate=yes
+
+const (
+	mountInfoFile  = "/proc/self/mountinfo"
+	procCgroupFile = "/proc/self/cgroup"
+	// 任务节点所在的cgroup
+	nodeCgroupName = "gocron-node"
+)
+
+var cgroupParent struct {
+	once        sync.Once
+	dir         string
+	controllers map[string]bool // 已为子cgroup启用的控制器
+	err         error
+}
+
+var taskCgroupSeq int64
+
+type taskCgroup struct {
+	dir string
+}
+
+// 为任务创建cgroup并设置资源限制
+func newTaskCgroup(limits ResourceLimits) (*taskCgroup, error) {
+	parent, controllers, err := loadCgroupParent()
+	if err != nil {
+		return nil, fmt.Errorf("cgroup v2 is not available: %s", err)
+	}
+	required := []string{"memory"}
+	if limits.CPU > 0 {
+		required = append(required, "cpu")
+	}
+	if limits.Pids > 0 {
+		required = append(required, "pids")
+	}
+	for _, name := range required {
+		if !controllers[name] {
+			return nil, fmt.Errorf("cgroup controller %s is not enabled", name)
+		}
+	}
+	name := fmt.Sprintf("task-%d-%d", time.Now().UnixNano(), atomic.AddInt64(&taskCgroupSeq, 1))
+	g := &taskCgroup{dir: filepath.Join(parent, name)}
+	err = os.Mkdir(g.dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	err = g.setLimits(limits)
+	if err != nil {
+		os.Remove(g.dir)
+		return nil, err
+	}
+
+	return g, nil
+}
+
+func (g *taskCgroup) setLimits(limits ResourceLimits) error {
+	if limits.Memory > 0 {
+		err := writeCgroupFile(g.dir, "memory.max", strconv.FormatInt(int64(limits.Memory)*1024*1024, 10))
+		if err != nil {
+			return err
+		}
+		// 未开启swap统计时文件不存在
+		writeCgroupFile(g.dir, "memory.swap.max", "0")
+	}
+	if limits.CPU > 0 {
+		// 每100ms周期内可使用的CPU时间(微秒)
+		err := writeCgroupFile(g.dir, "cpu.max", fmt.Sprintf("%d 100000", limits.CPU*1000))
+		if err != nil {
+			return err
+		}
+	}
+	if limits.Pids > 0 {
+		err := writeCgroupFile(g.dir, "pids.max", strconv.Itoa(limits.Pids))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 启动进程并移入cgroup, 命令执行前已在cgroup中, 创建的子进程均受限制
+// 先启动/bin/sh等待管道关闭, 移入cgroup后关闭管道, sh通过exec执行命令, 进程ID不变
+func (g *taskCgroup) start(cmd *exec.Cmd) error {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	fd := 3 + len(cmd.ExtraFiles)
+	script := fmt.Sprintf(`read _ <&%d; exec %d<&-; exec "$0" "$@"`, fd, fd)
+	cmd.Args = append([]string{"/bin/sh", "-c", script, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = "/bin/sh"
+	cmd.ExtraFiles = append(cmd.ExtraFiles, reader)
+	err = cmd.Start()
+	reader.Close()
+	if err != nil {
+		return err
+	}
+	err = writeCgroupFile(g.dir, "cgroup.procs", strconv.Itoa(cmd.Process.Pid))
+	if err != nil {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		cmd.Wait()
+		return err
+	}
+
+	return nil
+}
+
+// 结束cgroup中的所有进程, 包括脱离进程组的后台进程
+func (g *taskCgroup) kill() {
+	err := writeCgroupFile(g.dir, "cgroup.kill", "1")
+	if err == nil {
+		return
+	}
+	// 内核5.14之前不支持cgroup.kill
+	for _, pid := range g.pids() {
+		syscall.Kill(pid, syscall.SIGKILL)
+	}
+}
+
+// 内存使用峰值(字节)、CPU时间, 是否发生OOM
+// 内核5.19之前不支持memory.peak, 返回0
+func (g *taskCgroup) usage() (int64, time.Duration, bool) {
+	peakMemory, _ := strconv.ParseInt(readCgroupFile(g.dir, "memory.peak"), 10, 64)
+	usec := cgroupStat(readCgroupFile(g.dir, "cpu.stat"), "usage_usec")
+	oomKill := cgroupStat(readCgroupFile(g.dir, "memory.events"), "oom_kill")
+
+	return peakMemory, time.Duration(usec) * time.Microsecond, oomKill > 0
+}
+
+// 删除cgroup, 命令退出后仍在运行的后台进程移回任务节点所在的cgroup
+func (g *taskCgroup) remove() {
+	nodeDir := filepath.Join(filepath.Dir(g.dir), nodeCgroupName)
+	for i := 0; i < 20; i++ {
+		for _, pid := range g.pids() {
+			writeCgroupFile(nodeDir, "cgroup.procs", strconv.Itoa(pid))
+		}
+		if os.Remove(g.dir) == nil {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (g *taskCgroup) pids() []int {
+	pids := make([]int, 0)
+	for _, line := range strings.Split(readCgroupFile(g.dir, "cgroup.procs"), "\n") {
+		pid, err := strconv.Atoi(line)
+		if err == nil {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids
+}
+
+func loadCgroupParent() (string, map[string]bool, error) {
+	p := &cgroupParent
+	p.once.Do(func() {
+		p.dir, p.controllers, p.err = initCgroupParent()
+	})
+
+	return p.dir, p.controllers, p.err
+}
+
+// 任务节点移入子cgroup, 为任务cgroup启用cpu、memory、pids控制器
+func initCgroupParent() (string, map[string]bool, error) {
+	mountInfo, err := ioutil.ReadFile(mountInfoFile)
+	if err != nil {
+		return "", nil, err
+	}
+	mountPoint, mountRoot, err := parseCgroup2Mount(string(mountInfo))
+	if err != nil {
+		return "", nil, err
+	}
+	procCgroup, err := ioutil.ReadFile(procCgroupFile)
+	if err != nil {
+		return "", nil, err
+	}
+	path, err := parseCgroup2Path(string(procCgroup))
+	if err != nil {
+		return "", nil, err
+	}
+	relPath, err := filepath.Rel(mountRoot, path)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return "", nil, fmt.Errorf("cgroup %s is not under mount root %s", path, mountRoot)
+	}
+	dir := filepath.Join(mountPoint, relPath)
+	if filepath.Base(dir) == nodeCgroupName {
+		dir = filepath.Dir(dir)
+	}
+	// 统计内存峰值需要memory控制器, 不可用时不移动任务节点
+	available := cgroupControllers(readCgroupFile(dir, "cgroup.controllers"))
+	if !available["memory"] {
+		return "", nil, errors.New("cgroup controller memory is not available")
+	}
+	nodeDir := filepath.Join(dir, nodeCgroupName)
+	err = os.MkdirAll(nodeDir, 0755)
+	if err != nil {
+		return "", nil, err
+	}
+	err = writeCgroupFile(nodeDir, "cgroup.procs", strconv.Itoa(os.Getpid()))
+	if err != nil {
+		return "", nil, err
+	}
+	for _, name := range []string{"cpu", "memory", "pids"} {
+		if available[name] {
+			writeCgroupFile(dir, "cgroup.subtree_control", "+"+name)
+		}
+	}
+
+	return dir, cgroupControllers(readCgroupFile(dir, "cgroup.subtree_control")), nil
+}
+
+// 从/proc/self/mountinfo中解析cgroup2的挂载点及挂载的根路径
+func parseCgroup2Mount(mountInfo string) (string, string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(mountInfo))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		for i, field := range fields {
+			if field == "-" && i+1 < len(fields) && fields[i+1] == "cgroup2" && len(fields) > 4 {
+				return fields[4], fields[3], nil
+			}
+		}
+	}
+
+	return "", "", errors.New("cgroup2 is not mounted")
+}
+
+// 从/proc/self/cgroup中解析进程所在的cgroup v2路径
+func parseCgroup2Path(procCgroup string) (string, error) {
+	for _, line := range strings.Split(procCgroup, "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::"), nil
+		}
+	}
+
+	return "", errors.New("process is not in a cgroup v2 hierarchy")
+}
+
+func cgroupControllers(content string) map[string]bool {
+	controllers := make(map[string]bool)
+	for _, name := range strings.Fields(content) {
+		controllers[name] = true
+	}
+
+	return controllers
+}
+
+// 解析cpu.stat、memory.events等 key value 格式的文件
+func cgroupStat(content string, key string) int64 {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			value, _ := strconv.ParseInt(fields[1], 10, 64)
+			return value
+		}
+	}
+
+	return 0
+}
+
+func readCgroupFile(dir, name string) string {
+	content, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(content))
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	return ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+}
+
+// 进程内存使用峰值(字节), linux下ru_maxrss单位为KB
+func maxRSS(rusage *syscall.Rusage) int64 {
+	return int64(rusage.Maxrss) * 1024
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestParseCgroup2(t *testing.T) {
+	mountInfo := "25 30 0:23 / /sys/fs/cgroup/memory rw,relatime shared:9 - cgroup cgroup rw,memory\n" +
+		"35 30 0:30 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:4 - cgroup2 cgroup2 rw,nsdelegate\n"
+	mountPoint, mountRoot, err := parseCgroup2Mount(mountInfo)
+	if err != nil || mountPoint != "/sys/fs/cgroup" || mountRoot != "/" {
+		t.Fatalf("解析cgroup2挂载点错误, 实际%s %s %v", mountPoint, mountRoot, err)
+	}
+	_, _, err = parseCgroup2Mount("25 30 0:23 / /sys/fs/cgroup/memory rw - cgroup cgroup rw,memory\n")
+	if err == nil {
+		t.Fatal("未挂载cgroup2时应返回错误")
+	}
+
+	path, err := parseCgroup2Path("4:memory:/user.slice\n0::/system.slice/gocron-node.service\n")
+	if err != nil || path != "/system.slice/gocron-node.service" {
+		t.Fatalf("解析cgroup路径错误, 实际%s %v", path, err)
+	}
+
+	stat := "usage_usec 1520341\nuser_usec 1200000\nsystem_usec 320341"
+	if value := cgroupStat(stat, "usage_usec"); value != 1520341 {
+		t.Fatalf("usage_usec期望1520341, 实际%d", value)
+	}
+	if value := cgroupStat("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1", "oom_kill"); value != 1 {
+		t.Fatalf("oom_kill期望1, 实际%d", value)
+	}
+}
+
+func TestExecShellStreamWithoutLimits(t *testing.T) {
+	before, _ := ioutil.ReadFile(procCgroupFile)
+	output := new(ShellOutput)
+	_, err := ExecShellStream(context.Background(), ShellCommand{Command: "echo ok"}, output.StdoutWriter(), output.StderrWriter())
+	if err != nil || output.Stdout() != "ok\n" {
+		t.Fatalf("执行命令失败, 输出%q %v", output.Stdout(), err)
+	}
+	after, _ := ioutil.ReadFile(procCgroupFile)
+	if cgroupParent.dir != "" || cgroupParent.err != nil || !bytes.Equal(before, after) {
+		t.Fatalf("未设置资源限制时不应使用cgroup, 任务节点cgroup: %s -> %s", before, after)
+	}
+}
+
+func TestTaskCgroupStart(t *testing.T) {
+	// 普通目录代替cgroup, 检查写入cgroup.procs的进程与执行命令的进程相同
+	dir, err := ioutil.TempDir("", "gocron-cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	g := &taskCgroup{dir: dir}
+	cmd := exec.Command("/bin/sh", "-c", `echo "$$ $1"`, "sh", "a b")
+	stdout := new(bytes.Buffer)
+	cmd.Stdout = stdout
+	err = g.start(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmd.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid := readCgroupFile(dir, "cgroup.procs")
+	if strings.TrimSpace(stdout.String()) != pid+" a b" {
+		t.Fatalf("命令应在移入cgroup的进程中执行且参数不变, 进程%s, 输出%q", pid, stdout.String())
+	}
+}
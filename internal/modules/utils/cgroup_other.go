@@ -0,0 +1,38 @@
+// +build !linux,!windows
+
+package utils
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+type taskCgroup struct{}
+
+func newTaskCgroup(limits ResourceLimits) (*taskCgroup, error) {
+	return nil, errors.New("resource limits are only supported on linux")
+}
+
+func (g *taskCgroup) start(cmd *exec.Cmd) error {
+	return cmd.Start()
+}
+
+func (g *taskCgroup) kill() {}
+
+func (g *taskCgroup) usage() (int64, time.Duration, bool) {
+	return 0, 0, false
+}
+
+func (g *taskCgroup) remove() {}
+
+// 进程内存使用峰值(字节), ru_maxrss单位mac下为字节, 其他平台为KB
+func maxRSS(rusage *syscall.Rusage) int64 {
+	if runtime.GOOS == "darwin" {
+		return int64(rusage.Maxrss)
+	}
+
+	return int64(rusage.Maxrss) * 1024
+}
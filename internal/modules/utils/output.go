@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// 限制长度的输出缓冲区, 超出最大长度时保留开头和末尾各一半, 丢弃中间部分
+type CappedBuffer struct {
+	MaxSize int // 最大长度(字节), 0不限制
+	head    []byte
+	tail    []byte
+	size    int64
+}
+
+func (b *CappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.size += int64(n)
+	if b.MaxSize <= 0 {
+		b.head = append(b.head, p...)
+		return n, nil
+	}
+	headSize, tailSize := b.MaxSize/2, b.MaxSize-b.MaxSize/2
+	if len(b.head) < headSize {
+		length := headSize - len(b.head)
+		if length > len(p) {
+			length = len(p)
+		}
+		b.head = append(b.head, p[:length]...)
+		p = p[length:]
+	}
+	if len(p) >= tailSize {
+		b.tail = append(b.tail[:0], p[len(p)-tailSize:]...)
+		return n, nil
+	}
+	b.tail = append(b.tail, p...)
+	// 末尾部分超出2倍长度时再丢弃, 避免每次写入都移动数据
+	if len(b.tail) > 2*tailSize {
+		b.tail = append(b.tail[:0], b.tail[len(b.tail)-tailSize:]...)
+	}
+
+	return n, nil
+}
+
+// 写入的总长度
+func (b *CappedBuffer) Size() int64 {
+	return b.size
+}
+
+// 是否超出最大长度
+func (b *CappedBuffer) Truncated() bool {
+	return b.MaxSize > 0 && b.size > int64(b.MaxSize)
+}
+
+func (b *CappedBuffer) String() string {
+	if !b.Truncated() {
+		return string(b.head) + string(b.tail)
+	}
+	head := trimIncompleteRuneEnd(b.head)
+	tail := b.tail[len(b.tail)-(b.MaxSize-b.MaxSize/2):]
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+	omitted := b.size - int64(len(head)) - int64(len(tail))
+
+	return fmt.Sprintf("%s\n\n...... 输出超出最大长度, 省略%d字节 ......\n\n%s", head, omitted, tail)
+}
+
+// 输出超出最大长度时保留开头和末尾部分
+func TruncateOutput(output string, maxSize int) string {
+	if maxSize <= 0 || len(output) <= maxSize {
+		return output
+	}
+	buffer := &CappedBuffer{MaxSize: maxSize}
+	buffer.Write([]byte(output))
+
+	return buffer.String()
+}
+
+// 去掉末尾不完整的多字节字符
+func trimIncompleteRuneEnd(p []byte) []byte {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(p[i]) {
+			continue
+		}
+		if !utf8.FullRune(p[i:]) {
+			return p[:i]
+		}
+		break
+	}
+
+	return p
+}
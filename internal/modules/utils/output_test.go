@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	buffer := &CappedBuffer{MaxSize: 10}
+	buffer.Write([]byte("0123"))
+	buffer.Write([]byte("456789"))
+	if buffer.Truncated() || buffer.String() != "0123456789" {
+		t.Fatalf("未超出最大长度时应保留完整输出, 实际%s", buffer.String())
+	}
+	for i := 0; i < 100; i++ {
+		buffer.Write([]byte("abc"))
+	}
+	buffer.Write([]byte("vwxyz"))
+	output := buffer.String()
+	if !buffer.Truncated() || !strings.HasPrefix(output, "01234\n") || !strings.HasSuffix(output, "\nvwxyz") {
+		t.Fatalf("超出最大长度时应保留开头和末尾, 实际%s", output)
+	}
+	if !strings.Contains(output, "省略305字节") {
+		t.Fatalf("省略字节数错误, 实际%s", output)
+	}
+
+	// 不截断多字节字符
+	output = TruncateOutput(strings.Repeat("中", 10), 10)
+	if !strings.HasPrefix(output, "中\n") || !strings.HasSuffix(output, "\n中") {
+		t.Fatalf("截断多字节字符, 实际%q", output)
+	}
+}
@@ -3,14 +3,21 @@ package utils
 import (
 	"crypto/md5"
 	crand "crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math/rand"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Tang-RoseChild/mahonia"
+	"golang.org/x/net/context"
 )
 
 func RandAuthToken() string {
@@ -45,6 +52,13 @@ func Md5(str string) string {
 	return hex.EncodeToString(m.Sum(nil))
 }
 
+// 生成64位SHA256摘要
+func Sha256(str string) string {
+	h := sha256.Sum256([]byte(str))
+
+	return hex.EncodeToString(h[:])
+}
+
 // 生成0-max之间随机数
 func RandNumber(max int) int {
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -106,3 +120,181 @@ func FileExist(file string) bool {
 
 	return true
 }
+
+// shell命令及执行环境
+type ShellCommand struct {
+	Command string
+	Shell   string   // 解释器, 为空时使用默认shell, 只有解释器名称时追加参数-c, 如 sh、python3 -c
+	Dir     string   // 工作目录, 为空时使用当前目录
+	Env     []string // 附加的环境变量(key=value)
+	User    string   // 执行用户, 为空时使用当前用户
+	Group   string   // 执行用户组, 为空时使用执行用户的主组
+	Limits  ResourceLimits
+	// 超时或停止时先发送StopSignal(默认SIGTERM), 等待StopGrace后进程仍未退出时发送SIGKILL, StopGrace为0时直接发送SIGKILL
+	StopSignal string
+	StopGrace  time.Duration
+	// 脚本内容, 不为空时写入私有临时目录, 由Interpreter执行, Command为空格分隔的脚本参数
+	Script      string
+	Interpreter string // 脚本解释器及参数, 如 python3、perl -w
+	scriptFile  string
+}
+
+// 资源限制, 0为不限制
+type ResourceLimits struct {
+	CPU    int // CPU使用率上限(%), 100为1个核
+	Memory int // 内存上限(MB)
+	Pids   int // 最大进程数
+}
+
+func (l ResourceLimits) Empty() bool {
+	return l.CPU <= 0 && l.Memory <= 0 && l.Pids <= 0
+}
+
+// 命令行参数, defaultShell为默认解释器及参数
+func (c ShellCommand) args(defaultShell ...string) []string {
+	if c.scriptFile != "" {
+		args := append(strings.Fields(c.Interpreter), c.scriptFile)
+		return append(args, strings.Fields(c.Command)...)
+	}
+	shell := strings.Fields(c.Shell)
+	switch len(shell) {
+	case 0:
+		shell = defaultShell
+	case 1:
+		shell = append(shell, "-c")
+	}
+
+	return append(shell, c.Command)
+}
+
+// 创建命令, 设置工作目录、环境变量
+func (c ShellCommand) cmd(defaultShell ...string) *exec.Cmd {
+	args := c.args(defaultShell...)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = c.Dir
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+
+	return cmd
+}
+
+// 检查工作目录, 目录不存在时启动命令返回的错误为解释器不存在, 难以定位
+func (c ShellCommand) checkDir() error {
+	if c.Dir == "" {
+		return nil
+	}
+	info, err := os.Stat(c.Dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", c.Dir)
+	}
+
+	return nil
+}
+
+// 脚本写入私有临时目录, uid不小于0时目录及文件属主改为执行用户, 执行结束后调用remove删除
+func (c *ShellCommand) writeScript(name string, uid, gid int) (remove func(), err error) {
+	dir, err := ioutil.TempDir("", "gocron-script-")
+	if err != nil {
+		return nil, err
+	}
+	remove = func() {
+		os.RemoveAll(dir)
+	}
+	file := filepath.Join(dir, name)
+	err = ioutil.WriteFile(file, []byte(c.Script), 0600)
+	if err == nil && uid >= 0 {
+		err = os.Chown(dir, uid, gid)
+		if err == nil {
+			err = os.Chown(file, uid, gid)
+		}
+	}
+	if err != nil {
+		remove()
+		return nil, err
+	}
+	c.scriptFile = file
+
+	return remove, nil
+}
+
+// 超时或停止的原因
+func stopReason(ctx context.Context) string {
+	if ctx.Err() == context.DeadlineExceeded {
+		return "timeout"
+	}
+
+	return "manually stopped"
+}
+
+// 命令退出状态
+type ExitStatus struct {
+	ExitCode int    // 退出码, 被信号终止时为-1
+	Signal   string // 终止进程的信号
+	// 资源使用情况, 使用cgroup时包含所有子进程, 否则内存峰值为单个进程的峰值
+	PeakMemory int64         // 内存使用峰值(字节)
+	CPUTime    time.Duration // CPU时间, 用户态+内核态
+}
+
+// 命令输出, 同时记录合并后的输出和分开的标准输出、错误输出
+type ShellOutput struct {
+	mu       sync.Mutex
+	combined CappedBuffer
+	stdout   CappedBuffer
+	stderr   CappedBuffer
+}
+
+// 创建命令输出, maxSize为输出最大长度(字节), 超出时保留开头和末尾部分, 0不限制
+func NewShellOutput(maxSize int) *ShellOutput {
+	output := new(ShellOutput)
+	output.combined.MaxSize = maxSize
+	output.stdout.MaxSize = maxSize
+	output.stderr.MaxSize = maxSize
+
+	return output
+}
+
+func (o *ShellOutput) StdoutWriter() io.Writer {
+	return shellOutputWriter{o, &o.stdout}
+}
+
+func (o *ShellOutput) StderrWriter() io.Writer {
+	return shellOutputWriter{o, &o.stderr}
+}
+
+func (o *ShellOutput) Combined() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.combined.String()
+}
+
+func (o *ShellOutput) Stdout() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.stdout.String()
+}
+
+func (o *ShellOutput) Stderr() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.stderr.String()
+}
+
+type shellOutputWriter struct {
+	output *ShellOutput
+	buf    *CappedBuffer
+}
+
+func (w shellOutputWriter) Write(p []byte) (int, error) {
+	w.output.mu.Lock()
+	defer w.output.mu.Unlock()
+	w.output.combined.Write(p)
+
+	return w.buf.Write(p)
+}
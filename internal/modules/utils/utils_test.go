@@ -1,6 +1,13 @@
 package utils
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
 
 func TestRandString(t *testing.T) {
 	str := RandString(32)
@@ -22,3 +29,51 @@ func TestRandNumber(t *testing.T) {
 		t.Fatalf("随机数不在有效范围内-%d", num)
 	}
 }
+
+func TestShellCommandArgs(t *testing.T) {
+	commands := []struct {
+		shell    string
+		expected []string
+	}{
+		{"", []string{"/bin/bash", "-c", "echo"}},
+		{"sh", []string{"sh", "-c", "echo"}},
+		{"/usr/bin/python3 -u -c", []string{"/usr/bin/python3", "-u", "-c", "echo"}},
+	}
+	for _, item := range commands {
+		args := ShellCommand{Command: "echo", Shell: item.shell}.args("/bin/bash", "-c")
+		if !reflect.DeepEqual(args, item.expected) {
+			t.Fatalf("命令行参数不匹配, 期望%v, 实际%v", item.expected, args)
+		}
+	}
+}
+
+func TestShellCommandScript(t *testing.T) {
+	command := ShellCommand{Command: "--full  /data", Script: "echo $1\n", Interpreter: "/bin/sh -e"}
+	remove, err := command.writeScript("script", -1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(command.scriptFile)
+	if err != nil || string(content) != command.Script {
+		t.Fatalf("脚本内容不匹配, 实际%q, err: %v", content, err)
+	}
+	expected := []string{"/bin/sh", "-e", command.scriptFile, "--full", "/data"}
+	if args := command.args("/bin/bash", "-c"); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("命令行参数不匹配, 期望%v, 实际%v", expected, args)
+	}
+	remove()
+	if _, err := os.Stat(filepath.Dir(command.scriptFile)); !os.IsNotExist(err) {
+		t.Fatalf("脚本目录未删除, err: %v", err)
+	}
+}
+
+func TestValidStopSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows下不使用停止信号")
+	}
+	for name, valid := range map[string]bool{"": true, "SIGINT": true, "term": true, "SIGFOO": false, "9": false} {
+		if ValidStopSignal(name) != valid {
+			t.Errorf("停止信号%q期望有效: %v", name, valid)
+		}
+	}
+}
@@ -4,35 +4,272 @@ package utils
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"golang.org/x/net/context"
 )
 
-type Result struct {
-	output string
-	err    error
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGALRM: "SIGALRM",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGUSR1: "SIGUSR1",
+	syscall.SIGUSR2: "SIGUSR2",
+	syscall.SIGXCPU: "SIGXCPU",
+	syscall.SIGXFSZ: "SIGXFSZ",
 }
 
 // 执行shell命令，可设置执行超时时间
 func ExecShell(ctx context.Context, command string) (string, error) {
-	cmd := exec.Command("/bin/bash", "-c", command)
+	output := new(ShellOutput)
+	_, err := ExecShellStream(ctx, ShellCommand{Command: command}, output.StdoutWriter(), output.StderrWriter())
+	if ctx.Err() != nil {
+		return "", err
+	}
+
+	return output.Combined(), err
+}
+
+// 执行shell命令, 标准输出、错误输出产生时立即写入, 默认使用bash执行
+// linux下设置了资源限制时进程放入cgroup, 限制资源使用并统计所有子进程的资源使用情况, 未设置资源限制时不使用cgroup
+func ExecShellStream(ctx context.Context, command ShellCommand, stdout, stderr io.Writer) (ExitStatus, error) {
+	err := command.checkDir()
+	if err != nil {
+		return ExitStatus{ExitCode: -1}, err
+	}
+	var credential *syscall.Credential
+	var userEnv []string
+	if command.User != "" {
+		credential, userEnv, err = lookupCredential(command.User, command.Group)
+		if err != nil {
+			return ExitStatus{ExitCode: -1}, err
+		}
+	}
+	if command.Script != "" {
+		if command.Interpreter == "" {
+			command.Interpreter = "/bin/bash"
+		}
+		uid, gid := -1, -1
+		if credential != nil {
+			uid, gid = int(credential.Uid), int(credential.Gid)
+		}
+		removeScript, err := command.writeScript("script", uid, gid)
+		if err != nil {
+			return ExitStatus{ExitCode: -1}, err
+		}
+		defer removeScript()
+	}
+	cmd := command.cmd("/bin/bash", "-c")
 	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
+		Setpgid:    true,
+		Credential: credential,
+	}
+	if credential != nil {
+		cmd.Env = append(append(os.Environ(), userEnv...), command.Env...)
+	}
+	var cgroup *taskCgroup
+	if !command.Limits.Empty() {
+		cgroup, err = newTaskCgroup(command.Limits)
+		if err != nil {
+			return ExitStatus{ExitCode: -1}, err
+		}
+		defer cgroup.remove()
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if cgroup != nil {
+		err = cgroup.start(cmd)
+	} else {
+		err = cmd.Start()
 	}
-	resultChan := make(chan Result)
+	if err != nil {
+		return ExitStatus{ExitCode: -1}, err
+	}
+	errChan := make(chan error, 1)
 	go func() {
-		output, err := cmd.CombinedOutput()
-		resultChan <- Result{string(output), err}
+		errChan <- cmd.Wait()
 	}()
 	select {
 	case <-ctx.Done():
-		if cmd.Process.Pid > 0 {
-			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		status := stopProcess(cmd, command, cgroup, errChan)
+		action := "terminated"
+		if status.Signal == signalName(syscall.SIGKILL) {
+			action = "killed"
+		}
+		return status, fmt.Errorf("%s, %s by %s", stopReason(ctx), action, status.Signal)
+	case err = <-errChan:
+		status := exitStatus(cmd)
+		if cgroup == nil {
+			return status, err
+		}
+		peakMemory, cpuTime, oomKilled := cgroup.usage()
+		if peakMemory > 0 {
+			status.PeakMemory = peakMemory
+		}
+		status.CPUTime = cpuTime
+		if oomKilled && err != nil {
+			err = errors.New("memory limit exceeded, killed by oom killer")
+		}
+		return status, err
+	}
+}
+
+// 结束进程组, 先发送停止信号, 等待一段时间后仍未退出时发送SIGKILL, 返回的Signal为结束进程的信号
+func stopProcess(cmd *exec.Cmd, command ShellCommand, cgroup *taskCgroup, errChan chan error) ExitStatus {
+	pid := cmd.Process.Pid
+	// 未设置时使用SIGTERM, 无效的信号在执行前已拒绝
+	sig, ok := signalValue(command.StopSignal)
+	if !ok {
+		sig = syscall.SIGTERM
+	}
+	if command.StopGrace > 0 && sig != syscall.SIGKILL {
+		syscall.Kill(-pid, sig)
+		select {
+		case <-errChan:
+			return processUsage(exitStatus(cmd), signalName(sig), cgroup)
+		case <-time.After(command.StopGrace):
+		}
+	}
+	syscall.Kill(-pid, syscall.SIGKILL)
+	if cgroup != nil {
+		cgroup.kill()
+	}
+	// 后台进程未关闭输出时Wait不会返回
+	select {
+	case <-errChan:
+		return processUsage(exitStatus(cmd), signalName(syscall.SIGKILL), cgroup)
+	case <-time.After(time.Second):
+		return processUsage(ExitStatus{ExitCode: -1}, signalName(syscall.SIGKILL), cgroup)
+	}
+}
+
+// 设置结束进程的信号, 使用cgroup时统计资源使用情况
+func processUsage(status ExitStatus, signal string, cgroup *taskCgroup) ExitStatus {
+	status.Signal = signal
+	if cgroup != nil {
+		peakMemory, cpuTime, _ := cgroup.usage()
+		if peakMemory > 0 {
+			status.PeakMemory = peakMemory
+		}
+		status.CPUTime = cpuTime
+	}
+
+	return status
+}
+
+func exitStatus(cmd *exec.Cmd) ExitStatus {
+	status := ExitStatus{
+		ExitCode: cmd.ProcessState.ExitCode(),
+		CPUTime:  cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime(),
+	}
+	if rusage, ok := cmd.ProcessState.SysUsage().(*syscall.Rusage); ok {
+		status.PeakMemory = maxRSS(rusage)
+	}
+	waitStatus, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if !ok {
+		return status
+	}
+	if waitStatus.Signaled() {
+		status.ExitCode = -1
+		status.Signal = signalName(waitStatus.Signal())
+	} else {
+		status.ExitCode = waitStatus.ExitStatus()
+	}
+
+	return status
+}
+
+// 停止信号是否有效, 为空时使用SIGTERM
+func ValidStopSignal(name string) bool {
+	if strings.TrimSpace(name) == "" {
+		return true
+	}
+	_, ok := signalValue(name)
+
+	return ok
+}
+
+// 根据名称获取信号, 如SIGTERM、TERM
+func signalValue(name string) (syscall.Signal, bool) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	for sig, item := range signalNames {
+		if item == name {
+			return sig, true
 		}
-		return "", errors.New("timeout killed")
-	case result := <-resultChan:
-		return result.output, result.err
 	}
+
+	return 0, false
 }
+
+func signalName(sig syscall.Signal) string {
+	name, ok := signalNames[sig]
+	if ok {
+		return name
+	}
+
+	return sig.String()
+}
+
+// 执行用户的uid、gid及附加组, 以及HOME、USER等环境变量
+// 切换用户需以root用户运行或具有CAP_SETUID、CAP_SETGID权限
+func lookupCredential(username, group string) (*syscall.Credential, []string, error) {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return nil, nil, err
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return nil, nil, err
+		}
+		gid, err = strconv.ParseUint(g.Gid, 10, 32)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	groups := make([]uint32, 0)
+	groupIds, _ := u.GroupIds()
+	for _, id := range groupIds {
+		value, err := strconv.ParseUint(id, 10, 32)
+		if err == nil {
+			groups = append(groups, uint32(value))
+		}
+	}
+	credential := &syscall.Credential{
+		Uid:    uint32(uid),
+		Gid:    uint32(gid),
+		Groups: groups,
+	}
+	env := []string{"HOME=" + u.HomeDir, "USER=" + u.Username, "LOGNAME=" + u.Username}
+
+	return credential, env, nil
+}
+
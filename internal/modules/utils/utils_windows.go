@@ -4,6 +4,8 @@ package utils
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"os/exec"
 	"strconv"
 	"syscall"
@@ -11,22 +13,60 @@ import (
 	"golang.org/x/net/context"
 )
 
-type Result struct {
-	output string
-	err    error
-}
-
 // 执行shell命令，可设置执行超时时间
 func ExecShell(ctx context.Context, command string) (string, error) {
-	cmd := exec.Command("cmd", "/C", command)
+	output := new(ShellOutput)
+	_, err := execShell(ctx, ShellCommand{Command: command}, output.StdoutWriter(), output.StderrWriter())
+	if ctx.Err() != nil {
+		return "", err
+	}
+
+	return ConvertEncoding(output.Combined()), err
+}
+
+// 执行shell命令, 标准输出、错误输出产生时立即写入, 默认使用cmd执行
+func ExecShellStream(ctx context.Context, command ShellCommand, stdout, stderr io.Writer) (ExitStatus, error) {
+	return execShell(ctx, command, encodingWriter{stdout}, encodingWriter{stderr})
+}
+
+func execShell(ctx context.Context, command ShellCommand, stdout, stderr io.Writer) (ExitStatus, error) {
+	if command.User != "" || command.Group != "" {
+		return ExitStatus{ExitCode: -1}, errors.New("run as user is not supported on windows")
+	}
+	if !command.Limits.Empty() {
+		return ExitStatus{ExitCode: -1}, errors.New("resource limits are not supported on windows")
+	}
+	err := command.checkDir()
+	if err != nil {
+		return ExitStatus{ExitCode: -1}, err
+	}
+	if command.Script != "" {
+		// cmd按扩展名执行脚本文件
+		name := "script"
+		if command.Interpreter == "" {
+			command.Interpreter = "cmd /C"
+			name = "script.bat"
+		}
+		removeScript, err := command.writeScript(name, -1, -1)
+		if err != nil {
+			return ExitStatus{ExitCode: -1}, err
+		}
+		defer removeScript()
+	}
+	cmd := command.cmd("cmd", "/C")
 	// 隐藏cmd窗口
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		HideWindow: true,
 	}
-	var resultChan chan Result = make(chan Result)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err = cmd.Start()
+	if err != nil {
+		return ExitStatus{ExitCode: -1}, err
+	}
+	errChan := make(chan error, 1)
 	go func() {
-		output, err := cmd.CombinedOutput()
-		resultChan <- Result{string(output), err}
+		errChan <- cmd.Wait()
 	}()
 	select {
 	case <-ctx.Done():
@@ -34,12 +74,35 @@ func ExecShell(ctx context.Context, command string) (string, error) {
 			exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
 			cmd.Process.Kill()
 		}
-		return "", errors.New("timeout killed")
-	case result := <-resultChan:
-		return ConvertEncoding(result.output), result.err
+		// windows不支持发送停止信号, 直接结束进程
+		return ExitStatus{ExitCode: -1}, fmt.Errorf("%s, killed", stopReason(ctx))
+	case err = <-errChan:
+		return ExitStatus{
+			ExitCode: cmd.ProcessState.ExitCode(),
+			CPUTime:  cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime(),
+		}, err
 	}
 }
 
+// 输出片段转换为utf8后写入
+type encodingWriter struct {
+	w io.Writer
+}
+
+func (e encodingWriter) Write(p []byte) (int, error) {
+	_, err := e.w.Write([]byte(ConvertEncoding(string(p))))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Windows下直接结束进程, 不使用停止信号
+func ValidStopSignal(name string) bool {
+	return true
+}
+
 func ConvertEncoding(outputGBK string) string {
 	// windows平台编码为gbk，需转换为utf8才能入库
 	outputUTF8, ok := GBK2UTF8(outputGBK)
@@ -0,0 +1,155 @@
+package host
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-macaron/binding"
+	"github.com/ouqiang/gocron/internal/models"
+	"github.com/ouqiang/gocron/internal/modules/logger"
+	"github.com/ouqiang/gocron/internal/modules/utils"
+	"github.com/ouqiang/gocron/internal/service"
+	macaron "gopkg.in/macaron.v1"
+)
+
+// 任务节点启动时注册, 之后定时发送心跳
+type AgentForm struct {
+	AgentId string `binding:"Required;MaxSize(64)"`
+	Name    string `binding:"MaxSize(64)"` // 任务节点地址, 为空时使用请求的来源IP
+	Port    int    `binding:"Required;Range(1,65535)"`
+	Alias   string
+	Labels  string `binding:"MaxSize(512)"`
+	Version string `binding:"MaxSize(32)"`
+	Os      string `binding:"MaxSize(32)"`
+}
+
+func (f AgentForm) Error(ctx *macaron.Context, errs binding.Errors) {
+	if len(errs) == 0 {
+		return
+	}
+	json := utils.JsonResponse{}
+	content := json.CommonFailure("表单验证失败, 请检测输入")
+	ctx.Write([]byte(content))
+}
+
+// Register 任务节点注册, 按节点标识匹配主机, 未注册过时按地址(主机名+端口)匹配手动添加的主机, 都不存在时创建
+func Register(ctx *macaron.Context, form AgentForm) string {
+	json := utils.JsonResponse{}
+	name := strings.TrimSpace(form.Name)
+	if name == "" {
+		name = ctx.RemoteAddr()
+	}
+	hostModel := new(models.Host)
+	err := hostModel.FindByAgentId(form.AgentId)
+	if err != nil {
+		return json.CommonFailure("操作失败", err)
+	}
+	if hostModel.Id == 0 {
+		err = hostModel.FindByAddr(name, form.Port)
+		if err != nil {
+			return json.CommonFailure("操作失败", err)
+		}
+		if hostModel.Id > 0 && hostModel.AgentId != "" && hostModel.Status == models.HostOnline {
+			logger.Warnf("任务节点注册失败#地址已被其他在线节点使用#%s#%s:%d", form.AgentId, name, form.Port)
+			return json.CommonFailure("主机地址已被其他在线节点使用")
+		}
+	} else {
+		addrExist, err := hostModel.AddrExists(name, form.Port, hostModel.Id)
+		if err != nil {
+			return json.CommonFailure("操作失败", err)
+		}
+		if addrExist {
+			logger.Warnf("任务节点注册失败#地址已被其他主机使用#%s#%s:%d", form.AgentId, name, form.Port)
+			return json.CommonFailure("主机地址已被其他主机使用")
+		}
+	}
+
+	oldHostModel := *hostModel
+	hostModel.AgentId = form.AgentId
+	hostModel.Name = name
+	hostModel.Port = form.Port
+	// 任务节点未设置标签时保留页面上设置的标签
+	if strings.TrimSpace(form.Labels) != "" {
+		labels, err := service.ParseHostLabels(form.Labels)
+		if err != nil {
+			return json.CommonFailure(err.Error())
+		}
+		hostModel.Labels = formatLabels(labels)
+	}
+	hostModel.Version = strings.TrimSpace(form.Version)
+	hostModel.Os = strings.TrimSpace(form.Os)
+	hostModel.Status = models.HostOnline
+	hostModel.LastSeen = time.Now()
+	if hostModel.Id == 0 {
+		hostModel.Alias = truncate(strings.TrimSpace(form.Alias), 32)
+		if hostModel.Alias == "" {
+			hostModel.Alias = name
+		}
+		hostModel.Remark = "自动注册"
+		_, err = hostModel.Create()
+		if err != nil {
+			return json.CommonFailure("保存失败", err)
+		}
+		logger.Infof("任务节点注册#新增主机#%s#%s:%d", form.AgentId, name, form.Port)
+
+		return json.Success("注册成功", hostModel.Id)
+	}
+
+	_, err = hostModel.Register(hostModel.Id)
+	if err != nil {
+		return json.CommonFailure("保存失败", err)
+	}
+	logger.Infof("任务节点注册#主机ID-%d#%s#%s:%d", hostModel.Id, form.AgentId, name, form.Port)
+	if oldHostModel.Name != hostModel.Name || oldHostModel.Port != hostModel.Port {
+		err = refreshTasks(hostModel.Id, &oldHostModel, hostModel)
+		if err != nil {
+			return json.CommonFailure("刷新任务主机信息失败", err)
+		}
+	}
+
+	return json.Success("注册成功", hostModel.Id)
+}
+
+// Heartbeat 任务节点心跳, 任务节点未注册或主机已删除时返回NotFound, 任务节点重新注册
+func Heartbeat(ctx *macaron.Context) string {
+	json := utils.JsonResponse{}
+	agentId := ctx.QueryTrim("agent_id")
+	if agentId == "" {
+		return json.CommonFailure("参数agent_id不能为空")
+	}
+	hostModel := new(models.Host)
+	count, err := hostModel.Heartbeat(agentId)
+	if err != nil {
+		return json.CommonFailure("操作失败", err)
+	}
+	if count == 0 {
+		return json.Failure(utils.NotFound, "任务节点未注册")
+	}
+
+	return json.Success("", nil)
+}
+
+// 按标签名排序后逗号分隔
+func formatLabels(labels map[string]string) string {
+	items := make([]string, 0, len(labels))
+	for key, value := range labels {
+		if value != "" {
+			key += "=" + value
+		}
+		items = append(items, key)
+	}
+	sort.Strings(items)
+
+	return strings.Join(items, ",")
+}
+
+// 按字符截取, 避免截断多字节字符
+func truncate(s string, length int) string {
+	runes := []rune(s)
+	if len(runes) > length {
+		return string(runes[:length])
+	}
+
+	return s
+}
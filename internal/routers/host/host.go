@@ -74,6 +74,7 @@ type HostForm struct {
 	Name   string `binding:"Required;MaxSize(64)"`
 	Alias  string `binding:"Required;MaxSize(32)"`
 	Port   int    `binding:"Required;Range(1-65535)"`
+	Labels string `binding:"MaxSize(512)"`
 	Remark string
 }
 
@@ -104,6 +105,11 @@ func Store(ctx *macaron.Context, form HostForm) string {
 	hostModel.Alias = strings.TrimSpace(form.Alias)
 	hostModel.Port = form.Port
 	hostModel.Remark = strings.TrimSpace(form.Remark)
+	labels, err := service.ParseHostLabels(form.Labels)
+	if err != nil {
+		return json.CommonFailure(err.Error())
+	}
+	hostModel.Labels = formatLabels(labels)
 	isCreate := false
 	oldHostModel := new(models.Host)
 	err = oldHostModel.Find(int(id))
@@ -122,23 +128,33 @@ func Store(ctx *macaron.Context, form HostForm) string {
 	}
 
 	if !isCreate {
-		oldAddr := fmt.Sprintf("%s:%d", oldHostModel.Name, oldHostModel.Port)
-		newAddr := fmt.Sprintf("%s:%d", hostModel.Name, hostModel.Port)
-		if oldAddr != newAddr {
-			grpcpool.Pool.Release(oldAddr)
-		}
-
-		taskModel := new(models.Task)
-		tasks, err := taskModel.ActiveListByHostId(id)
+		err = refreshTasks(id, oldHostModel, hostModel)
 		if err != nil {
 			return json.CommonFailure("刷新任务主机信息失败", err)
 		}
-		service.ServiceTask.BatchAdd(tasks)
 	}
 
 	return json.Success("保存成功", nil)
 }
 
+// 主机地址变更后释放旧连接, 重新加载引用此主机的任务
+func refreshTasks(id int16, oldHost, newHost *models.Host) error {
+	oldAddr := fmt.Sprintf("%s:%d", oldHost.Name, oldHost.Port)
+	newAddr := fmt.Sprintf("%s:%d", newHost.Name, newHost.Port)
+	if oldAddr != newAddr {
+		grpcpool.Pool.Release(oldAddr)
+	}
+
+	taskModel := new(models.Task)
+	tasks, err := taskModel.ActiveListByHostId(id)
+	if err != nil {
+		return err
+	}
+	service.ServiceTask.BatchAdd(tasks)
+
+	return nil
+}
+
 // Remove 删除主机
 func Remove(ctx *macaron.Context) string {
 	id, err := strconv.Atoi(ctx.Params(":id"))
@@ -20,12 +20,12 @@ import (
 // 系统安装
 
 type InstallForm struct {
-	DbType               string `binding:"In(mysql,postgres)"`
-	DbHost               string `binding:"Required;MaxSize(50)"`
-	DbPort               int    `binding:"Required;Range(1,65535)"`
-	DbUsername           string `binding:"Required;MaxSize(50)"`
-	DbPassword           string `binding:"Required;MaxSize(30)"`
-	DbName               string `binding:"Required;MaxSize(50)"`
+	DbType               string `binding:"In(mysql,postgres,sqlite)"`
+	DbHost               string `binding:"MaxSize(50)"`
+	DbPort               int    `binding:"OmitEmpty;Range(1,65535)"`
+	DbUsername           string `binding:"MaxSize(50)"`
+	DbPassword           string `binding:"MaxSize(30)"`
+	DbName               string `binding:"Required;MaxSize(255)"` // SQLite为数据库文件路径
 	DbTablePrefix        string `binding:"MaxSize(20)"`
 	AdminUsername        string `binding:"Required;MinSize(3)"`
 	AdminPassword        string `binding:"Required;MinSize(6)"`
@@ -48,9 +48,16 @@ func Store(ctx *macaron.Context, form InstallForm) string {
 	if app.Installed {
 		return json.CommonFailure("系统已安装!")
 	}
+	if form.DbType == "sqlite" && !models.SqliteAvailable() {
+		return json.CommonFailure("当前版本不支持SQLite, 需以CGO_ENABLED=1编译")
+	}
 	if form.AdminPassword != form.ConfirmAdminPassword {
 		return json.CommonFailure("两次输入密码不匹配")
 	}
+	if form.DbType != "sqlite" &&
+		(form.DbHost == "" || form.DbPort == 0 || form.DbUsername == "" || form.DbPassword == "") {
+		return json.CommonFailure("请输入数据库主机名、端口、用户名和密码")
+	}
 	err := testDbConnection(form)
 	if err != nil {
 		return json.CommonFailure(err.Error())
@@ -65,6 +72,7 @@ func Store(ctx *macaron.Context, form InstallForm) string {
 	if err != nil {
 		return json.CommonFailure("读取应用配置失败", err)
 	}
+	app.InitInstance(appConfig)
 	app.Setting = appConfig
 
 	models.Db = models.CreateDb()
@@ -93,10 +101,22 @@ func Store(ctx *macaron.Context, form InstallForm) string {
 	app.Installed = true
 	// 初始化定时任务
 	service.ServiceTask.Initialize()
+	service.ServiceHost.Start()
 
 	return json.Success("安装成功", nil)
 }
 
+// 可选的数据库类型
+func DbTypes(ctx *macaron.Context) string {
+	json := utils.JsonResponse{}
+	dbTypes := []string{"mysql", "postgres"}
+	if models.SqliteAvailable() {
+		dbTypes = append(dbTypes, "sqlite")
+	}
+
+	return json.Success("", dbTypes)
+}
+
 // 配置写入文件
 func writeConfig(form InstallForm) error {
 	dbConfig := []string{
@@ -120,6 +140,7 @@ func writeConfig(form InstallForm) error {
 		"ca_file", "",
 		"cert_file", "",
 		"key_file", "",
+		"agent.token", "",
 	}
 
 	return setting.Write(dbConfig, app.AppConfig)
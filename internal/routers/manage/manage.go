@@ -6,6 +6,7 @@ import (
 	"github.com/ouqiang/gocron/internal/models"
 	"github.com/ouqiang/gocron/internal/modules/logger"
 	"github.com/ouqiang/gocron/internal/modules/utils"
+	"github.com/ouqiang/gocron/internal/service"
 	"gopkg.in/macaron.v1"
 )
 
@@ -128,3 +129,18 @@ func UpdateWebHook(ctx *macaron.Context) string {
 }
 
 // endregion
+
+// region 集群
+
+// 集群状态, 展示当前持有调度器租约的实例
+func Cluster(ctx *macaron.Context) string {
+	jsonResp := utils.JsonResponse{}
+	status, err := service.ServiceCluster.Status()
+	if err != nil {
+		return jsonResp.CommonFailure("获取集群状态失败", err)
+	}
+
+	return jsonResp.Success(utils.SuccessContent, status)
+}
+
+// endregion
@@ -1,6 +1,7 @@
 package routers
 
 import (
+	"crypto/subtle"
 	"io"
 	"log"
 	"net/http"
@@ -21,6 +22,7 @@ import (
 	"github.com/ouqiang/gocron/internal/routers/task"
 	"github.com/ouqiang/gocron/internal/routers/tasklog"
 	"github.com/ouqiang/gocron/internal/routers/user"
+	"github.com/ouqiang/gocron/internal/routers/workflow"
 	"github.com/rakyll/statik/fs"
 	"gopkg.in/macaron.v1"
 
@@ -61,6 +63,7 @@ func Register(m *macaron.Macaron) {
 	// 系统安装
 	m.Group("/install", func() {
 		m.Post("/store", binding.Bind(install.InstallForm{}), install.Store)
+		m.Get("/db-types", install.DbTypes)
 		m.Get("/status", func(ctx *macaron.Context) string {
 			jsonResp := utils.JsonResponse{}
 			return jsonResp.Success("", app.Installed)
@@ -83,9 +86,14 @@ func Register(m *macaron.Macaron) {
 	// 定时任务
 	m.Group("/task", func() {
 		m.Post("/store", binding.Bind(task.TaskForm{}), task.Store)
+		m.Get("/cron/preview", task.CronPreview)
 		m.Get("/:id", task.Detail)
 		m.Get("", task.Index)
 		m.Get("/log", tasklog.Index)
+		m.Get("/log/tail", tasklog.Tail)
+		m.Get("/log/output", tasklog.Output)
+		m.Get("/log/host", tasklog.Host)
+		m.Get("/workflow", workflow.Index)
 		m.Post("/log/clear", tasklog.Clear)
 		m.Post("/log/stop", tasklog.Stop)
 		m.Post("/remove/:id", task.Remove)
@@ -123,6 +131,7 @@ func Register(m *macaron.Macaron) {
 			m.Post("/update", manage.UpdateWebHook)
 		})
 		m.Get("/login-log", loginlog.Index)
+		m.Get("/cluster", manage.Cluster)
 	})
 
 	// API
@@ -132,6 +141,12 @@ func Register(m *macaron.Macaron) {
 		m.Post("/task/disable/:id", task.Disable)
 	}, apiAuth)
 
+	// 任务节点自动注册
+	m.Group("/agent", func() {
+		m.Post("/register", binding.Bind(host.AgentForm{}), host.Register)
+		m.Post("/heartbeat", host.Heartbeat)
+	}, agentAuth)
+
 	// 404错误
 	m.NotFound(func(ctx *macaron.Context) string {
 		jsonResp := utils.JsonResponse{}
@@ -220,7 +235,7 @@ func userAuth(ctx *macaron.Context) {
 		return
 	}
 	uri := strings.TrimRight(ctx.Req.URL.Path, "/")
-	if strings.HasPrefix(uri, "/v1") {
+	if strings.HasPrefix(uri, "/v1") || strings.HasPrefix(uri, "/agent/") {
 		return
 	}
 	excludePaths := []string{"", "/user/login", "/install/status"}
@@ -244,7 +259,7 @@ func urlAuth(ctx *macaron.Context) {
 		return
 	}
 	uri := strings.TrimRight(ctx.Req.URL.Path, "/")
-	if strings.HasPrefix(uri, "/v1") {
+	if strings.HasPrefix(uri, "/v1") || strings.HasPrefix(uri, "/agent/") {
 		return
 	}
 	// 普通用户允许访问的URL地址
@@ -253,6 +268,10 @@ func urlAuth(ctx *macaron.Context) {
 		"/install/status",
 		"/task",
 		"/task/log",
+		"/task/log/tail",
+		"/task/log/output",
+		"/task/log/host",
+		"/task/workflow",
 		"/host",
 		"/host/all",
 		"/user/login",
@@ -313,4 +332,25 @@ func apiAuth(ctx *macaron.Context) {
 	}
 }
 
+/** 任务节点令牌验证 **/
+func agentAuth(ctx *macaron.Context) {
+	if !app.Installed {
+		return
+	}
+	json := utils.JsonResponse{}
+	token := strings.TrimSpace(app.Setting.Agent.Token)
+	if token == "" {
+		msg := json.CommonFailure("使用任务节点自动注册前, 请先配置agent.token")
+		ctx.Write([]byte(msg))
+		return
+	}
+	agentToken := ctx.Req.Header.Get("Agent-Token")
+	if subtle.ConstantTimeCompare([]byte(agentToken), []byte(token)) != 1 {
+		logger.Warnf("任务节点令牌验证失败-%s", ctx.RemoteAddr())
+		msg := json.Failure(utils.AuthError, "令牌验证失败")
+		ctx.Write([]byte(msg))
+		return
+	}
+}
+
 // endregion
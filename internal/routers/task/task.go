@@ -1,13 +1,12 @@
 package task
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
-
-	"github.com/ouqiang/goutil"
+	"time"
 
 	"github.com/go-macaron/binding"
-	"github.com/jakecoffman/cron"
 	"github.com/ouqiang/gocron/internal/models"
 	"github.com/ouqiang/gocron/internal/modules/logger"
 	"github.com/ouqiang/gocron/internal/modules/utils"
@@ -16,27 +15,54 @@ import (
 	"gopkg.in/macaron.v1"
 )
 
+// shell任务可设置的停止信号
+var stopSignals = []string{"SIGTERM", "SIGINT", "SIGQUIT", "SIGHUP", "SIGUSR1", "SIGUSR2", "SIGKILL"}
+
+// 脚本内容最大长度, 随执行请求发送到任务节点
+const maxScriptSize = 1 << 20
+
 type TaskForm struct {
-	Id               int
-	Level            models.TaskLevel `binding:"Required;In(1,2)"`
-	DependencyStatus models.TaskDependencyStatus
-	DependencyTaskId string
-	Name             string `binding:"Required;MaxSize(32)"`
-	Spec             string
-	Protocol         models.TaskProtocol   `binding:"In(1,2)"`
-	Command          string                `binding:"Required;MaxSize(256)"`
-	HttpMethod       models.TaskHTTPMethod `binding:"In(1,2)"`
-	Timeout          int                   `binding:"Range(0,86400)"`
-	Multi            int8                  `binding:"In(1,2)"`
-	RetryTimes       int8
-	RetryInterval    int16
-	HostId           string
-	Tag              string
-	Remark           string
-	NotifyStatus     int8 `binding:"In(1,2,3,4)"`
-	NotifyType       int8 `binding:"In(1,2,3,4)"`
-	NotifyReceiverId string
-	NotifyKeyword    string
+	Id                int
+	Level             models.TaskLevel `binding:"Required;In(1,2)"`
+	DependencyStatus  models.TaskDependencyStatus
+	DependencyTaskId  string
+	DependencyTrigger models.TaskDependencyTrigger `binding:"In(0,1,2)"`
+	Name              string                       `binding:"Required;MaxSize(32)"`
+	Spec              string
+	Timezone          string                `binding:"MaxSize(64)"`
+	Protocol          models.TaskProtocol   `binding:"In(1,2,3)"`
+	Command           string                `binding:"MaxSize(256)"`
+	Env               string                `binding:"MaxSize(4096)"`
+	WorkDir           string                `binding:"MaxSize(256)"`
+	Shell             string                `binding:"MaxSize(128)"`
+	Interpreter       string                `binding:"MaxSize(64)"`
+	RunAsUser         string                `binding:"MaxSize(32)"`
+	RunAsGroup        string                `binding:"MaxSize(32)"`
+	CpuLimit          int                   `binding:"Range(0,100000)"`
+	MemoryLimit       int                   `binding:"Range(0,1048576)"`
+	PidsLimit         int                   `binding:"Range(0,1000000)"`
+	StopSignal        string                `binding:"MaxSize(16)"`
+	StopGrace         int                   `binding:"Range(0,3600)"`
+	HttpMethod        models.TaskHTTPMethod `binding:"In(1,2)"`
+	Timeout           int                   `binding:"Range(0,86400)"`
+	Multi             int8                  `binding:"In(1,2)"`
+	RetryTimes        int8
+	RetryInterval     int16
+	Script            string
+	HostId            string
+	HostSelector      string                      `binding:"MaxSize(256)"`
+	DispatchStrategy  models.TaskDispatchStrategy `binding:"In(0,1,2,3,4)"`
+	SuccessPolicy     models.TaskSuccessPolicy    `binding:"In(0,1,2,3)"`
+	SuccessThreshold  int16
+	Tag               string
+	Remark            string
+	NotifyStatus      int8 `binding:"In(1,2,3,4)"`
+	NotifyType        int8 `binding:"In(1,2,3,4)"`
+	NotifyReceiverId  string
+	NotifyKeyword     string
+	InterruptPolicy   models.TaskInterruptPolicy `binding:"In(0,1)"`
+	MisfirePolicy     models.TaskMisfirePolicy   `binding:"In(0,1,2)"`
+	MisfireLimit      int16
 }
 
 func (f TaskForm) Error(ctx *macaron.Context, errs binding.Errors) {
@@ -86,6 +112,37 @@ func Detail(ctx *macaron.Context) string {
 	return jsonResp.Success(utils.SuccessContent, task)
 }
 
+// CronPreview 校验crontab表达式, 返回之后的执行时间
+func CronPreview(ctx *macaron.Context) string {
+	json := utils.JsonResponse{}
+	spec := strings.TrimSpace(ctx.QueryTrim("spec"))
+	timezone := ctx.QueryTrim("timezone")
+	count := ctx.QueryInt("count")
+	if count <= 0 {
+		count = 5
+	}
+	if count > service.MaxPreviewCount {
+		count = service.MaxPreviewCount
+	}
+	location, err := service.LoadLocation(timezone)
+	if err != nil {
+		return json.CommonFailure(fmt.Sprintf("时区无效: %s", err))
+	}
+	times, err := service.NextRunTimes(spec, timezone, time.Now(), count)
+	if err != nil {
+		return json.CommonFailure(fmt.Sprintf("crontab表达式无效: %s", err))
+	}
+	nextRunTimes := make([]string, len(times))
+	for i, t := range times {
+		nextRunTimes[i] = t.Format(models.DefaultTimeFormat)
+	}
+
+	return json.Success(utils.SuccessContent, map[string]interface{}{
+		"timezone":       location.String(),
+		"next_run_times": nextRunTimes,
+	})
+}
+
 // 保存任务  todo 拆分为多个方法
 func Store(ctx *macaron.Context, form TaskForm) string {
 	json := utils.JsonResponse{}
@@ -99,8 +156,17 @@ func Store(ctx *macaron.Context, form TaskForm) string {
 		return json.CommonFailure("任务名称已存在")
 	}
 
-	if form.Protocol == models.TaskRPC && form.HostId == "" {
-		return json.CommonFailure("请选择主机名")
+	if form.Protocol.OnHost() {
+		selector, err := service.ParseHostSelector(form.HostSelector)
+		if err != nil {
+			return json.CommonFailure(err.Error())
+		}
+		if len(selector) == 0 {
+			form.HostSelector = ""
+		}
+		if form.HostId == "" && form.HostSelector == "" {
+			return json.CommonFailure("请选择主机名或设置主机标签选择器")
+		}
 	}
 
 	taskModel.Name = form.Name
@@ -119,10 +185,18 @@ func Store(ctx *macaron.Context, form TaskForm) string {
 	taskModel.NotifyType = form.NotifyType - 1
 	taskModel.NotifyReceiverId = form.NotifyReceiverId
 	taskModel.NotifyKeyword = form.NotifyKeyword
-	taskModel.Spec = form.Spec
+	taskModel.InterruptPolicy = form.InterruptPolicy
+	taskModel.MisfirePolicy = form.MisfirePolicy
+	taskModel.MisfireLimit = form.MisfireLimit
+	taskModel.Spec = strings.TrimSpace(form.Spec)
+	taskModel.Timezone = strings.TrimSpace(form.Timezone)
 	taskModel.Level = form.Level
 	taskModel.DependencyStatus = form.DependencyStatus
 	taskModel.DependencyTaskId = strings.TrimSpace(form.DependencyTaskId)
+	taskModel.DependencyTrigger = form.DependencyTrigger
+	if taskModel.DependencyTrigger != models.TaskDependencyTriggerAny {
+		taskModel.DependencyTrigger = models.TaskDependencyTriggerAll
+	}
 	if taskModel.NotifyStatus > 0 && taskModel.NotifyType != 3 && taskModel.NotifyReceiverId == "" {
 		return json.CommonFailure("至少选择一个通知接收者")
 	}
@@ -137,6 +211,66 @@ func Store(ctx *macaron.Context, form TaskForm) string {
 		}
 	}
 
+	if taskModel.Protocol == models.TaskRPC && taskModel.Command == "" {
+		return json.CommonFailure("请输入命令")
+	}
+
+	if taskModel.Protocol == models.TaskScript {
+		// 浏览器提交的换行为\r\n, 解释器无法识别
+		taskModel.Script = strings.Replace(form.Script, "\r\n", "\n", -1)
+		if strings.TrimSpace(taskModel.Script) == "" {
+			return json.CommonFailure("请输入脚本内容")
+		}
+		if len(taskModel.Script) > maxScriptSize {
+			return json.CommonFailure("脚本内容不能超过1MB")
+		}
+		taskModel.Interpreter = strings.TrimSpace(form.Interpreter)
+	}
+
+	if taskModel.Protocol.OnHost() {
+		taskModel.Env = strings.TrimSpace(form.Env)
+		taskModel.WorkDir = strings.TrimSpace(form.WorkDir)
+		if taskModel.Protocol == models.TaskRPC {
+			taskModel.Shell = strings.TrimSpace(form.Shell)
+		}
+		taskModel.RunAsUser = strings.TrimSpace(form.RunAsUser)
+		taskModel.RunAsGroup = strings.TrimSpace(form.RunAsGroup)
+		if taskModel.RunAsUser == "" && taskModel.RunAsGroup != "" {
+			return json.CommonFailure("设置执行用户组时需同时设置执行用户")
+		}
+		taskModel.CpuLimit = form.CpuLimit
+		taskModel.MemoryLimit = form.MemoryLimit
+		taskModel.PidsLimit = form.PidsLimit
+		taskModel.StopSignal = strings.ToUpper(strings.TrimSpace(form.StopSignal))
+		if taskModel.StopSignal != "" && !strings.HasPrefix(taskModel.StopSignal, "SIG") {
+			taskModel.StopSignal = "SIG" + taskModel.StopSignal
+		}
+		if taskModel.StopSignal != "" && !utils.InStringSlice(stopSignals, taskModel.StopSignal) {
+			return json.CommonFailure("不支持的停止信号")
+		}
+		taskModel.StopGrace = form.StopGrace
+		taskModel.HostSelector = strings.TrimSpace(form.HostSelector)
+		taskModel.DispatchStrategy = form.DispatchStrategy
+		taskModel.SuccessPolicy = form.SuccessPolicy
+		taskModel.SuccessThreshold = form.SuccessThreshold
+		switch taskModel.SuccessPolicy {
+		case models.TaskSuccessCount:
+			if taskModel.SuccessThreshold < 1 || taskModel.SuccessThreshold > 1000 {
+				return json.CommonFailure("成功主机数取值1-1000")
+			}
+		case models.TaskSuccessPercent:
+			if taskModel.SuccessThreshold < 1 || taskModel.SuccessThreshold > 100 {
+				return json.CommonFailure("成功主机比例取值1-100")
+			}
+		default:
+			taskModel.SuccessThreshold = 0
+		}
+		_, err = service.ParseTaskEnv(taskModel.Env)
+		if err != nil {
+			return json.CommonFailure(err.Error())
+		}
+	}
+
 	if taskModel.RetryTimes > 10 || taskModel.RetryTimes < 0 {
 		return json.CommonFailure("任务重试次数取值0-10")
 	}
@@ -145,28 +279,47 @@ func Store(ctx *macaron.Context, form TaskForm) string {
 		return json.CommonFailure("任务重试间隔时间取值0-3600")
 	}
 
+	if taskModel.MisfirePolicy == models.TaskMisfirePolicyRunAll &&
+		(taskModel.MisfireLimit < 1 || taskModel.MisfireLimit > service.MaxMisfireLimit) {
+		return json.CommonFailure(fmt.Sprintf("补执行次数上限取值1-%d", service.MaxMisfireLimit))
+	}
+
 	if taskModel.DependencyStatus != models.TaskDependencyStatusStrong &&
 		taskModel.DependencyStatus != models.TaskDependencyStatusWeak {
 		return json.CommonFailure("请选择依赖关系")
 	}
 
 	if taskModel.Level == models.TaskLevelParent {
-		err = goutil.PanicToError(func() {
-			cron.Parse(form.Spec)
-		})
+		_, err = service.LoadLocation(taskModel.Timezone)
+		if err != nil {
+			return json.CommonFailure(fmt.Sprintf("时区无效: %s", err))
+		}
+		_, err = service.NextRunTimes(taskModel.Spec, taskModel.Timezone, time.Now(), 1)
 		if err != nil {
-			return json.CommonFailure("crontab表达式解析失败", err)
+			return json.CommonFailure(fmt.Sprintf("crontab表达式无效: %s", err))
 		}
 	} else {
-		taskModel.DependencyTaskId = ""
 		taskModel.Spec = ""
+		taskModel.Timezone = ""
 	}
 
-	if id > 0 && taskModel.DependencyTaskId != "" {
-		dependencyTaskIds := strings.Split(taskModel.DependencyTaskId, ",")
-		if utils.InStringSlice(dependencyTaskIds, strconv.Itoa(id)) {
+	dependencyTaskIds, err := service.ParseDependencyTaskId(taskModel.DependencyTaskId)
+	if err != nil {
+		return json.CommonFailure(err.Error())
+	}
+	dependencyTaskIdList := make([]string, len(dependencyTaskIds))
+	for i, dependencyTaskId := range dependencyTaskIds {
+		dependencyTaskIdList[i] = strconv.Itoa(dependencyTaskId)
+	}
+	taskModel.DependencyTaskId = strings.Join(dependencyTaskIdList, ",")
+	if id > 0 && len(dependencyTaskIds) > 0 {
+		if utils.InStringSlice(dependencyTaskIdList, strconv.Itoa(id)) {
 			return json.CommonFailure("不允许设置当前任务为子任务")
 		}
+		err = service.CheckDependencyCycle(id, dependencyTaskIds)
+		if err != nil {
+			return json.CommonFailure(err.Error())
+		}
 	}
 
 	if id == 0 {
@@ -182,11 +335,13 @@ func Store(ctx *macaron.Context, form TaskForm) string {
 	}
 
 	taskHostModel := new(models.TaskHost)
-	if form.Protocol == models.TaskRPC {
-		hostIdStrList := strings.Split(form.HostId, ",")
-		hostIds := make([]int, len(hostIdStrList))
-		for i, hostIdStr := range hostIdStrList {
-			hostIds[i], _ = strconv.Atoi(hostIdStr)
+	if form.Protocol.OnHost() {
+		hostIds := make([]int, 0)
+		for _, hostIdStr := range strings.Split(form.HostId, ",") {
+			hostId, _ := strconv.Atoi(hostIdStr)
+			if hostId > 0 {
+				hostIds = append(hostIds, hostId)
+			}
 		}
 		taskHostModel.Add(id, hostIds)
 	} else {
@@ -196,6 +351,8 @@ func Store(ctx *macaron.Context, form TaskForm) string {
 	status, _ := taskModel.GetStatus(id)
 	if status == models.Enabled && taskModel.Level == models.TaskLevelParent {
 		addTaskToTimer(id)
+	} else {
+		service.ServiceTask.Remove(id)
 	}
 
 	return json.Success("保存成功", nil)
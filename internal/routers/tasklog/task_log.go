@@ -3,6 +3,9 @@ package tasklog
 // 任务日志
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/ouqiang/gocron/internal/models"
 	"github.com/ouqiang/gocron/internal/modules/logger"
 	"github.com/ouqiang/gocron/internal/modules/utils"
@@ -33,11 +36,16 @@ func Index(ctx *macaron.Context) string {
 // 清空日志
 func Clear(ctx *macaron.Context) string {
 	taskLogModel := new(models.TaskLog)
-	_, err := taskLogModel.Clear()
+	files, err := taskLogModel.OutputFiles(0)
+	if err != nil {
+		logger.Error(err)
+	}
+	_, err = taskLogModel.Clear()
 	json := utils.JsonResponse{}
 	if err != nil {
 		return json.CommonFailure(utils.FailureContent)
 	}
+	service.RemoveOutputFiles(files)
 
 	return json.Success(utils.SuccessContent, nil)
 }
@@ -52,20 +60,96 @@ func Stop(ctx *macaron.Context) string {
 	if err != nil {
 		return json.CommonFailure("获取任务信息失败#"+err.Error(), err)
 	}
-	if task.Protocol != models.TaskRPC {
-		return json.CommonFailure("仅支持SHELL任务手动停止")
+	if !task.Protocol.OnHost() {
+		return json.CommonFailure("仅支持SHELL、脚本任务手动停止")
 	}
-	if len(task.Hosts) == 0 {
-		return json.CommonFailure("任务节点列表为空")
+	hosts, err := service.ServiceTask.RunningHosts(id, int16(ctx.QueryInt("host_id")))
+	if err != nil {
+		return json.CommonFailure("获取执行中的主机失败", err)
 	}
-	for _, host := range task.Hosts {
-		service.ServiceTask.Stop(host.Name, host.Port, id)
-
+	if len(hosts) == 0 {
+		return json.CommonFailure("没有执行中的主机")
+	}
+	failures := make([]string, 0)
+	for _, host := range hosts {
+		err = service.ServiceTask.Stop(host.Name, host.Port, id)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s - %s: %s", host.Alias, host.Name, err))
+		}
+	}
+	if len(failures) == len(hosts) {
+		return json.CommonFailure("停止失败, " + strings.Join(failures, "; "))
+	}
+	if len(failures) > 0 {
+		return json.Success("已执行停止操作, 部分主机停止失败, "+strings.Join(failures, "; "), nil)
 	}
 
 	return json.Success("已执行停止操作, 请等待任务退出", nil)
 }
 
+// 查看运行中任务的实时输出, offset为上次返回的offset, 每次返回之后新增的输出
+// 输出超出最大长度后开头部分被丢弃, reset为true时表示返回的是完整输出, 需替换已显示内容
+// 任务不在当前实例运行或已结束时, 返回数据库中保存的输出
+func Tail(ctx *macaron.Context) string {
+	id := ctx.QueryInt64("id")
+	offset := ctx.QueryInt64("offset")
+	json := utils.JsonResponse{}
+	output, next, reset, running := service.ServiceTask.Tail(id, offset)
+	if !running {
+		taskLogModel := new(models.TaskLog)
+		err := taskLogModel.Find(id)
+		if err != nil || taskLogModel.Id == 0 {
+			return json.CommonFailure("任务日志不存在", err)
+		}
+		output = taskLogModel.Result
+		next = 0
+		reset = true
+		running = taskLogModel.Status == models.Running || taskLogModel.Status == models.Queued
+	}
+
+	return json.Success(utils.SuccessContent, map[string]interface{}{
+		"output":  output,
+		"offset":  next,
+		"reset":   reset,
+		"running": running,
+	})
+}
+
+// 主机执行记录, 包含输出
+func Host(ctx *macaron.Context) string {
+	id := ctx.QueryInt64("id")
+	json := utils.JsonResponse{}
+	logHostModel := new(models.TaskLogHost)
+	err := logHostModel.Find(id)
+	if err != nil || logHostModel.Id == 0 {
+		return json.CommonFailure("主机执行记录不存在", err)
+	}
+
+	return json.Success(utils.SuccessContent, logHostModel)
+}
+
+// 下载完整输出, gzip压缩
+func Output(ctx *macaron.Context) {
+	id := ctx.QueryInt64("id")
+	json := utils.JsonResponse{}
+	taskLogModel := new(models.TaskLog)
+	err := taskLogModel.Find(id)
+	if err != nil || taskLogModel.Id == 0 {
+		ctx.Write([]byte(json.CommonFailure("任务日志不存在", err)))
+		return
+	}
+	if taskLogModel.OutputFile == "" {
+		ctx.Write([]byte(json.CommonFailure("未保存完整输出")))
+		return
+	}
+	path := service.OutputFilePath(taskLogModel.OutputFile)
+	if !utils.FileExist(path) {
+		ctx.Write([]byte(json.CommonFailure("完整输出文件不存在")))
+		return
+	}
+	ctx.ServeFile(path, fmt.Sprintf("task-log-%d.log.gz", id))
+}
+
 // 删除N个月前的日志
 func Remove(ctx *macaron.Context) string {
 	month := ctx.ParamsInt(":id")
@@ -74,10 +158,15 @@ func Remove(ctx *macaron.Context) string {
 		return json.CommonFailure("参数取值范围1-12")
 	}
 	taskLogModel := new(models.TaskLog)
-	_, err := taskLogModel.Remove(month)
+	files, err := taskLogModel.OutputFiles(month)
+	if err != nil {
+		logger.Error(err)
+	}
+	_, err = taskLogModel.Remove(month)
 	if err != nil {
 		return json.CommonFailure("删除失败", err)
 	}
+	service.RemoveOutputFiles(files)
 
 	return json.Success("删除成功", nil)
 }
@@ -92,6 +181,7 @@ func parseQueryParams(ctx *macaron.Context) models.CommonMap {
 		status -= 1
 	}
 	params["Status"] = status
+	params["WorkflowRunId"] = ctx.QueryInt64("workflow_run_id")
 	base.ParsePageAndPageSize(ctx, params)
 
 	return params
@@ -0,0 +1,44 @@
+package workflow
+
+// 工作流执行记录
+
+import (
+	"github.com/ouqiang/gocron/internal/models"
+	"github.com/ouqiang/gocron/internal/modules/logger"
+	"github.com/ouqiang/gocron/internal/modules/utils"
+	"github.com/ouqiang/gocron/internal/routers/base"
+	"gopkg.in/macaron.v1"
+)
+
+func Index(ctx *macaron.Context) string {
+	runModel := new(models.WorkflowRun)
+	queryParams := parseQueryParams(ctx)
+	total, err := runModel.Total(queryParams)
+	if err != nil {
+		logger.Error(err)
+	}
+	runs, err := runModel.List(queryParams)
+	if err != nil {
+		logger.Error(err)
+	}
+	jsonResp := utils.JsonResponse{}
+
+	return jsonResp.Success(utils.SuccessContent, map[string]interface{}{
+		"total": total,
+		"data":  runs,
+	})
+}
+
+// 解析查询参数
+func parseQueryParams(ctx *macaron.Context) models.CommonMap {
+	var params models.CommonMap = models.CommonMap{}
+	params["TaskId"] = ctx.QueryInt("task_id")
+	status := ctx.QueryInt("status")
+	if status >= 0 {
+		status -= 1
+	}
+	params["Status"] = status
+	base.ParsePageAndPageSize(ctx, params)
+
+	return params
+}
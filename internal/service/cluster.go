@@ -0,0 +1,260 @@
+package service
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/ouqiang/gocron/internal/models"
+	"github.com/ouqiang/gocron/internal/modules/app"
+	"github.com/ouqiang/gocron/internal/modules/logger"
+)
+
+var ServiceCluster Cluster
+
+const (
+	// 调度器租约名称
+	schedulerLeaseName = "scheduler"
+	// 任务变更同步间隔
+	taskChangeSyncInterval = 3 * time.Second
+	// 任务变更记录保留时长
+	taskChangeRetention = 24 * time.Hour
+)
+
+var (
+	// 当前实例是否为主节点
+	clusterLeader int32
+	// 当前实例持有租约的过期时间
+	leaseExpireAt time.Time
+	// 停止租约续约、任务变更同步
+	clusterStop = make(chan struct{})
+	// 租约续约已停止
+	clusterDone = make(chan struct{})
+	// 当前实例启动时间, 之前开始的任务为重启前未执行完成的
+	instanceStarted time.Time
+	// 已同步的最新任务变更记录ID
+	lastTaskChangeId int64
+	// 任务变更同步失败, 需重新加载所有任务
+	taskChangeSyncFailed bool
+)
+
+// 集群, 多个实例通过数据库租约选举主节点, 只有主节点调度任务
+// 主节点每隔租约有效期的1/3续约一次, 主节点异常退出后, 其他实例最迟在租约过期后的一个续约周期内接管
+// 任务变更写入变更记录表, 所有实例轮询变更记录, 从数据库读取任务同步到各自的调度器
+type Cluster struct{}
+
+type ClusterStatus struct {
+	Enable   bool      `json:"enable"`
+	Instance string    `json:"instance"`
+	IsLeader bool      `json:"is_leader"`
+	Leader   string    `json:"leader"`
+	ExpireAt time.Time `json:"expire_at"`
+	Renewed  time.Time `json:"renewed"`
+}
+
+// 开始参与主节点选举
+func (c Cluster) Start() {
+	instanceStarted = time.Now().Truncate(time.Second)
+	ttl := time.Duration(app.Setting.Cluster.LeaseTTL) * time.Second
+	logger.Infof("集群模式已开启, 实例标识: %s, 租约有效期: %s", app.Setting.Cluster.Instance, ttl)
+	// 首次获取租约完成后返回, 中断任务重新执行前完成错过调度的计算
+	c.heartbeat(ttl/3, ttl)
+	go c.run(ttl)
+	go c.syncTaskChange()
+}
+
+// 停止续约并释放租约, 其他实例可立即接管
+func (c Cluster) Stop() {
+	close(clusterStop)
+	<-clusterDone
+	if !c.IsLeader() {
+		return
+	}
+	lease := new(models.SchedulerLease)
+	err := lease.Release(schedulerLeaseName, app.Setting.Cluster.Instance)
+	if err != nil {
+		logger.Error("集群#释放调度器租约失败", err)
+	}
+}
+
+// 当前实例是否为主节点
+func (c Cluster) IsLeader() bool {
+	return atomic.LoadInt32(&clusterLeader) == 1
+}
+
+// 集群状态
+func (c Cluster) Status() (ClusterStatus, error) {
+	status := ClusterStatus{
+		Enable:   app.Setting.Cluster.Enable,
+		Instance: app.Setting.Cluster.Instance,
+		IsLeader: c.IsLeader(),
+	}
+	if !status.Enable {
+		return status, nil
+	}
+	lease := new(models.SchedulerLease)
+	exist, err := lease.Get(schedulerLeaseName)
+	if err != nil || !exist {
+		return status, err
+	}
+	if lease.Valid() {
+		status.Leader = lease.Holder
+	}
+	status.ExpireAt = time.Unix(lease.ExpireAt, 0)
+	status.Renewed = time.Unix(lease.Renewed, 0)
+
+	return status, nil
+}
+
+func (c Cluster) run(ttl time.Duration) {
+	defer close(clusterDone)
+	interval := ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.heartbeat(interval, ttl)
+		case <-clusterStop:
+			return
+		}
+	}
+}
+
+// 续约实例心跳, 获取或续约租约, 根据结果启动或停止调度器
+// 主节点每次续约时接管已退出实例未执行完成的任务
+func (c Cluster) heartbeat(interval, ttl time.Duration) {
+	instance := app.Setting.Cluster.Instance
+	err := new(models.ClusterInstance).Renew(instance, instanceStarted, ttl)
+	if err != nil {
+		logger.Error("集群#续约实例心跳失败", err)
+	}
+	lease := new(models.SchedulerLease)
+	acquired, err := lease.Acquire(schedulerLeaseName, instance, ttl)
+	if err != nil {
+		logger.Error("集群#获取调度器租约失败", err)
+		// 已持有的租约在过期前不会被其他实例获取, 下次续约前不会过期则继续调度
+		if c.IsLeader() && time.Now().Add(interval).Before(leaseExpireAt) {
+			return
+		}
+	}
+	if acquired {
+		leaseExpireAt = time.Unix(lease.ExpireAt, 0)
+		if c.IsLeader() {
+			ServiceTask.rerunInterrupted(ServiceTask.markOrphanInterrupted())
+			return
+		}
+		// 非主节点期间任务变更未同步到调度器, 接管前重新加载
+		taskNum, err := ServiceTask.Reload()
+		if err != nil {
+			logger.Error("集群#重新加载定时任务失败", err)
+			lease.Release(schedulerLeaseName, instance)
+			return
+		}
+		atomic.StoreInt32(&clusterLeader, 1)
+		ServiceTask.recoverMisfired()
+		startScheduler()
+		logger.Infof("集群#当前实例成为主节点, 共%d个定时任务开始调度", taskNum)
+		ServiceTask.rerunInterrupted(ServiceTask.markOrphanInterrupted())
+		return
+	}
+	if !c.IsLeader() {
+		return
+	}
+	atomic.StoreInt32(&clusterLeader, 0)
+	stopScheduler()
+	logger.Warn("集群#当前实例失去主节点租约, 停止调度")
+}
+
+// 记录任务变更, 通知其他实例同步
+func (c Cluster) notifyTaskChange(taskIds ...int) {
+	if !app.Setting.Cluster.Enable {
+		return
+	}
+	change := new(models.TaskChange)
+	err := change.Create(app.Setting.Cluster.Instance, taskIds...)
+	if err != nil {
+		logger.Error("集群#记录任务变更失败", err)
+	}
+}
+
+// 记录当前最新的任务变更位置, 之后的变更由同步处理
+func (c Cluster) markTaskChange() error {
+	change := new(models.TaskChange)
+	id, err := change.LastId()
+	if err != nil {
+		return err
+	}
+	lastTaskChangeId = id
+
+	return nil
+}
+
+func (c Cluster) syncTaskChange() {
+	ticker := time.NewTicker(taskChangeSyncInterval)
+	defer ticker.Stop()
+	cleanTime := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+			c.applyTaskChange()
+			// 主节点定期清理过期的变更记录
+			if c.IsLeader() && time.Since(cleanTime) > time.Hour {
+				cleanTime = time.Now()
+				change := new(models.TaskChange)
+				_, err := change.RemoveBefore(time.Now().Add(-taskChangeRetention))
+				if err != nil {
+					logger.Error("集群#清理任务变更记录失败", err)
+				}
+			}
+		case <-clusterStop:
+			return
+		}
+	}
+}
+
+// 读取其他实例产生的任务变更, 同步到调度器
+func (c Cluster) applyTaskChange() {
+	if taskChangeSyncFailed {
+		// 上次同步失败, 可能遗漏变更, 重新加载所有任务
+		err := c.markTaskChange()
+		if err == nil {
+			_, err = ServiceTask.Reload()
+		}
+		if err != nil {
+			logger.Error("集群#重新加载定时任务失败", err)
+			return
+		}
+		taskChangeSyncFailed = false
+		return
+	}
+
+	change := new(models.TaskChange)
+	pageSize := 1000
+	for {
+		changes, err := change.ListAfter(lastTaskChangeId, pageSize)
+		if err != nil {
+			logger.Error("集群#获取任务变更记录失败", err)
+			taskChangeSyncFailed = true
+			return
+		}
+		taskIds := make(map[int]struct{})
+		for _, item := range changes {
+			lastTaskChangeId = item.Id
+			if item.Instance == app.Setting.Cluster.Instance {
+				continue
+			}
+			taskIds[item.TaskId] = struct{}{}
+		}
+		for taskId := range taskIds {
+			err = ServiceTask.reconcile(taskId)
+			if err != nil {
+				logger.Errorf("集群#同步任务失败#任务ID-%d#%s", taskId, err)
+				taskChangeSyncFailed = true
+				return
+			}
+		}
+		if len(changes) < pageSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ouqiang/gocron/internal/models"
+)
+
+// 多个主机时按任务的执行方式选择主机
+
+var (
+	// 各主机执行中的任务数, 仅统计本调度器发起的执行
+	hostRunning = runningCounter{count: make(map[int16]int)}
+
+	// 轮询的下一个位置, 任务ID作为Key
+	roundRobin = struct {
+		sync.Mutex
+		next map[int]int
+	}{next: make(map[int]int)}
+
+	dispatchRand = struct {
+		sync.Mutex
+		*rand.Rand
+	}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+)
+
+type runningCounter struct {
+	sync.Mutex
+	count map[int16]int
+}
+
+func (c *runningCounter) add(hostId int16) {
+	c.Lock()
+	defer c.Unlock()
+	c.count[hostId]++
+}
+
+func (c *runningCounter) done(hostId int16) {
+	c.Lock()
+	defer c.Unlock()
+	c.count[hostId]--
+	if c.count[hostId] <= 0 {
+		delete(c.count, hostId)
+	}
+}
+
+func (c *runningCounter) get(hostId int16) int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.count[hostId]
+}
+
+// 本次执行依次尝试的主机, 故障转移返回所有主机, 其他单主机执行方式返回选中的一个主机
+func dispatchHosts(taskModel models.Task, hosts []models.TaskHostDetail) []models.TaskHostDetail {
+	if len(hosts) <= 1 {
+		return hosts
+	}
+	var index int
+	switch taskModel.DispatchStrategy {
+	case models.TaskDispatchRoundRobin:
+		roundRobin.Lock()
+		index = roundRobin.next[taskModel.Id] % len(hosts)
+		roundRobin.next[taskModel.Id] = index + 1
+		roundRobin.Unlock()
+	case models.TaskDispatchRandom:
+		dispatchRand.Lock()
+		index = dispatchRand.Intn(len(hosts))
+		dispatchRand.Unlock()
+	case models.TaskDispatchLeastRunning:
+		least := -1
+		for i, host := range hosts {
+			running := hostRunning.get(host.HostId)
+			if least < 0 || running < least {
+				index, least = i, running
+			}
+		}
+	default:
+		return hosts
+	}
+
+	return hosts[index : index+1]
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ouqiang/gocron/internal/models"
+)
+
+func TestDispatchHosts(t *testing.T) {
+	hosts := []models.TaskHostDetail{
+		{TaskHost: models.TaskHost{HostId: 1}},
+		{TaskHost: models.TaskHost{HostId: 2}},
+		{TaskHost: models.TaskHost{HostId: 3}},
+	}
+	taskModel := models.Task{Id: 1, DispatchStrategy: models.TaskDispatchRoundRobin}
+	for _, expected := range []int16{1, 2, 3, 1} {
+		selected := dispatchHosts(taskModel, hosts)
+		if len(selected) != 1 || selected[0].HostId != expected {
+			t.Fatalf("轮询应选择主机%d, 实际%+v", expected, selected)
+		}
+	}
+
+	hostRunning.add(1)
+	hostRunning.add(2)
+	defer hostRunning.done(1)
+	defer hostRunning.done(2)
+	taskModel.DispatchStrategy = models.TaskDispatchLeastRunning
+	if selected := dispatchHosts(taskModel, hosts); len(selected) != 1 || selected[0].HostId != 3 {
+		t.Fatalf("应选择执行中任务最少的主机3, 实际%+v", selected)
+	}
+
+	taskModel.DispatchStrategy = models.TaskDispatchFailover
+	if selected := dispatchHosts(taskModel, hosts); len(selected) != 3 {
+		t.Fatalf("故障转移应按顺序返回所有主机, 实际%+v", selected)
+	}
+}
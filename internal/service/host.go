@@ -0,0 +1,36 @@
+package service
+
+import (
+	"time"
+
+	"github.com/ouqiang/gocron/internal/models"
+	"github.com/ouqiang/gocron/internal/modules/app"
+	"github.com/ouqiang/gocron/internal/modules/logger"
+)
+
+var ServiceHost Host
+
+// 离线检查间隔
+const hostOfflineCheckInterval = 10 * time.Second
+
+// 自动注册的任务节点定时发送心跳, 超过离线时间未收到心跳时标记为离线
+type Host struct{}
+
+func (h Host) Start() {
+	go h.checkOffline()
+}
+
+func (h Host) checkOffline() {
+	timeout := time.Duration(app.Setting.Agent.OfflineTimeout) * time.Second
+	hostModel := new(models.Host)
+	for range time.Tick(hostOfflineCheckInterval) {
+		count, err := hostModel.MarkOffline(time.Now().Add(-timeout))
+		if err != nil {
+			logger.Error("主机离线检查失败-", err)
+			continue
+		}
+		if count > 0 {
+			logger.Warnf("%d个主机超过%s未发送心跳, 标记为离线", count, timeout)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ouqiang/gocron/internal/models"
+)
+
+// 标签名及标签值, 如 role=worker, zone=cn-north-1a
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+
+// 任务日志hostname字段的长度
+const maxHostnameLength = 128
+
+// 解析主机标签, 逗号分隔, 如 role=worker,env=prod, 只有标签名时值为空
+func ParseHostLabels(labels string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, item := range strings.Split(labels, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value := item, ""
+		if pos := strings.Index(item, "="); pos >= 0 {
+			key, value = strings.TrimSpace(item[:pos]), strings.TrimSpace(item[pos+1:])
+		}
+		if !labelPattern.MatchString(key) || (value != "" && !labelPattern.MatchString(value)) {
+			return nil, fmt.Errorf("主机标签格式错误: %s, 应为 key=value", item)
+		}
+		result[key] = value
+	}
+
+	return result, nil
+}
+
+// 标签选择条件
+type labelRequirement struct {
+	key   string
+	value string
+	op    string // = 等于, != 不等于, exists 存在标签, !exists 不存在标签
+}
+
+func (r labelRequirement) match(labels map[string]string) bool {
+	value, ok := labels[r.key]
+	switch r.op {
+	case "=":
+		return ok && value == r.value
+	case "!=":
+		return !ok || value != r.value
+	case "exists":
+		return ok
+	default:
+		return !ok
+	}
+}
+
+// 主机标签选择器, 所有条件都满足时匹配
+type HostSelector []labelRequirement
+
+// 解析主机标签选择器, 逗号分隔的条件, 支持 key=value, key!=value, key(存在标签), !key(不存在标签)
+func ParseHostSelector(selector string) (HostSelector, error) {
+	result := make(HostSelector, 0)
+	for _, item := range strings.Split(selector, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var r labelRequirement
+		if pos := strings.Index(item, "!="); pos >= 0 {
+			r = labelRequirement{key: item[:pos], value: item[pos+2:], op: "!="}
+		} else if pos = strings.Index(item, "="); pos >= 0 {
+			r = labelRequirement{key: item[:pos], value: item[pos+1:], op: "="}
+		} else if strings.HasPrefix(item, "!") {
+			r = labelRequirement{key: item[1:], op: "!exists"}
+		} else {
+			r = labelRequirement{key: item, op: "exists"}
+		}
+		r.key, r.value = strings.TrimSpace(r.key), strings.TrimSpace(r.value)
+		if !labelPattern.MatchString(r.key) || (r.value != "" && !labelPattern.MatchString(r.value)) {
+			return nil, fmt.Errorf("主机标签选择器格式错误: %s", item)
+		}
+		result = append(result, r)
+	}
+
+	return result, nil
+}
+
+func (s HostSelector) Match(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.match(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 本次执行的主机, 任务绑定的主机及标签匹配的主机, 离线主机不参与标签匹配
+func resolveTaskHosts(taskModel models.Task) ([]models.TaskHostDetail, error) {
+	hosts := make([]models.TaskHostDetail, 0, len(taskModel.Hosts))
+	hostIds := make(map[int16]bool)
+	for _, host := range taskModel.Hosts {
+		if hostIds[host.HostId] {
+			continue
+		}
+		hostIds[host.HostId] = true
+		hosts = append(hosts, host)
+	}
+	if strings.TrimSpace(taskModel.HostSelector) == "" {
+		return hosts, nil
+	}
+	selector, err := ParseHostSelector(taskModel.HostSelector)
+	if err != nil {
+		return nil, err
+	}
+	hostModel := new(models.Host)
+	list, err := hostModel.SelectableList()
+	if err != nil {
+		return nil, fmt.Errorf("获取主机列表失败: %s", err)
+	}
+	for _, host := range list {
+		labels, err := ParseHostLabels(host.Labels)
+		if err != nil || !selector.Match(labels) || hostIds[host.Id] {
+			continue
+		}
+		hostIds[host.Id] = true
+		hosts = append(hosts, models.TaskHostDetail{
+			TaskHost: models.TaskHost{TaskId: taskModel.Id, HostId: host.Id},
+			Name:     host.Name,
+			Port:     host.Port,
+			Alias:    host.Alias,
+		})
+	}
+	if len(hosts) == 0 {
+		return nil, errors.New("没有匹配标签选择器的主机")
+	}
+
+	return hosts, nil
+}
+
+// 任务日志中记录的主机, 逗号分隔, 超出字段长度时截断, 完整的主机列表见各主机执行记录
+func formatTaskHosts(hosts []models.TaskHostDetail) string {
+	names := make([]string, len(hosts))
+	for i, host := range hosts {
+		names[i] = fmt.Sprintf("%s - %s", host.Alias, host.Name)
+	}
+	aggregationHost := []rune(strings.Join(names, ","))
+	if len(aggregationHost) > maxHostnameLength {
+		return string(aggregationHost[:maxHostnameLength-3]) + "..."
+	}
+
+	return string(aggregationHost)
+}
@@ -0,0 +1,39 @@
+package service
+
+import "testing"
+
+func TestHostSelectorMatch(t *testing.T) {
+	labels, err := ParseHostLabels("role=worker, env=prod,gpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		selector string
+		match    bool
+	}{
+		{"role=worker", true},
+		{"role=worker,env=prod", true},
+		{"role=worker,env=test", false},
+		{"env!=test", true},
+		{"zone!=a", true},
+		{"gpu", true},
+		{"!gpu", false},
+		{"!zone,role=worker", true},
+		{"", true},
+	}
+	for _, test := range tests {
+		selector, err := ParseHostSelector(test.selector)
+		if err != nil {
+			t.Fatalf("解析选择器%q失败: %s", test.selector, err)
+		}
+		if selector.Match(labels) != test.match {
+			t.Errorf("选择器%q匹配结果错误, 期望%v", test.selector, test.match)
+		}
+	}
+
+	for _, selector := range []string{"=worker", "role==worker", "role=a b", "!"} {
+		if _, err := ParseHostSelector(selector); err == nil {
+			t.Errorf("选择器%q格式错误时应返回错误", selector)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ouqiang/gocron/internal/modules/app"
+	"github.com/ouqiang/gocron/internal/modules/logger"
+	"github.com/ouqiang/gocron/internal/modules/utils"
+)
+
+// 输出超出最大长度时, 完整输出压缩保存到日志目录下的 output/任务日志ID.log.gz, 任务日志中只保存开头和末尾部分
+// 每个主机的输出先写入临时文件, 任务结束后按主机顺序合并, 多个gzip文件拼接后仍可直接解压
+// 任务重试时追加到同一文件, 保留之前执行的输出
+
+const outputFileDir = "output"
+
+// 主机的完整输出
+type outputSpill struct {
+	path   string
+	file   *os.File
+	writer *gzip.Writer
+	err    error
+}
+
+func newOutputSpill(taskLogId int64, index int) (*outputSpill, error) {
+	dir := filepath.Join(app.LogDir, outputFileDir)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%d-%d.tmp", taskLogId, index))
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &outputSpill{path: path, file: file, writer: gzip.NewWriter(file)}, nil
+}
+
+func (s *outputSpill) write(output string) {
+	if s.err != nil {
+		return
+	}
+	_, s.err = s.writer.Write([]byte(output))
+	if s.err != nil {
+		logger.Errorf("任务输出#写入输出文件失败#%s#%s", s.path, s.err)
+	}
+}
+
+func (s *outputSpill) close() {
+	err := s.writer.Close()
+	if s.err == nil {
+		s.err = err
+	}
+	s.file.Close()
+}
+
+// 主机输出即将超出最大长度时创建临时文件, 写入已有输出, 之后的输出同时写入临时文件
+func (o *TaskOutput) spill(host string, buffer *utils.CappedBuffer, output string) {
+	if !app.Setting.Output.Spill || buffer.MaxSize <= 0 {
+		return
+	}
+	spill, ok := o.spills[host]
+	if !ok {
+		if buffer.Size()+int64(len(output)) <= int64(buffer.MaxSize) {
+			return
+		}
+		var err error
+		spill, err = newOutputSpill(o.taskLogId, len(o.spills))
+		if err != nil {
+			logger.Error("任务输出#创建输出文件失败-", err)
+		}
+		o.spills[host] = spill
+		if spill != nil {
+			spill.write(fmt.Sprintf("主机: [%s]\n%s", host, buffer.String()))
+		}
+	}
+	if spill != nil {
+		spill.write(output)
+	}
+}
+
+// 合并各主机的完整输出, 返回相对日志目录的文件路径, 输出未超出最大长度且之前的执行未保存文件时返回空
+func (o *TaskOutput) SaveFile() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	name := filepath.Join(outputFileDir, fmt.Sprintf("%d.log.gz", o.taskLogId))
+	path := filepath.Join(app.LogDir, name)
+	info, statErr := os.Stat(path)
+	if len(o.spills) == 0 && statErr != nil {
+		return ""
+	}
+	defer func() {
+		for _, spill := range o.spills {
+			if spill != nil {
+				os.Remove(spill.path)
+			}
+		}
+		o.spills = make(map[string]*outputSpill)
+	}()
+	for _, spill := range o.spills {
+		if spill != nil {
+			spill.close()
+		}
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		logger.Error("任务输出#创建输出文件失败-", err)
+		return ""
+	}
+	defer file.Close()
+	if statErr == nil && info.Size() > 0 {
+		err = appendOutput(file, "...... 重试执行 ......\n\n")
+	}
+	for _, host := range o.hosts {
+		if err != nil {
+			break
+		}
+		spill := o.spills[host]
+		if spill != nil && spill.err == nil {
+			err = appendOutputFile(file, spill.path, "\n\n")
+		} else {
+			err = appendOutput(file, fmt.Sprintf("主机: [%s]\n%s\n\n", host, o.outputs[host].String()))
+		}
+	}
+	if err != nil {
+		logger.Errorf("任务输出#保存输出文件失败#%s#%s", path, err)
+		// 之前执行保存的输出仍保留
+		if statErr != nil {
+			file.Close()
+			os.Remove(path)
+			return ""
+		}
+	}
+
+	return name
+}
+
+// 追加主机的临时文件, suffix作为单独的gzip成员写入
+func appendOutputFile(w io.Writer, path string, suffix string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	if err != nil {
+		return err
+	}
+
+	return appendOutput(w, suffix)
+}
+
+func appendOutput(w io.Writer, output string) error {
+	writer := gzip.NewWriter(w)
+	_, err := writer.Write([]byte(output))
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// 完整输出文件的绝对路径
+func OutputFilePath(name string) string {
+	return filepath.Join(app.LogDir, filepath.Clean("/"+name))
+}
+
+// 删除任务日志时删除完整输出文件
+func RemoveOutputFiles(names []string) {
+	for _, name := range names {
+		err := os.Remove(OutputFilePath(name))
+		if err != nil && !os.IsNotExist(err) {
+			logger.Error("删除任务输出文件失败-", err)
+		}
+	}
+}
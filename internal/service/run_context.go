@@ -0,0 +1,149 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ouqiang/gocron/internal/models"
+)
+
+// 任务执行上下文, 包含任务、工作流信息, 依赖任务执行时还包含上游任务的执行结果
+// RPC任务以环境变量的形式传递, HTTP任务以请求头、URL中的模板变量{{变量名}}的形式传递
+
+// 传递给下游任务的上游任务输出最大长度, 超出时保留末尾部分
+const maxRunContextOutput = 32 * 1024
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// 本次执行的上下文, taskLogId为本次执行的任务日志ID
+func runContext(taskModel models.Task, taskLogId int64) map[string]string {
+	context := map[string]string{
+		"GOCRON_TASK_ID":     strconv.Itoa(taskModel.Id),
+		"GOCRON_TASK_NAME":   taskModel.Name,
+		"GOCRON_TASK_LOG_ID": strconv.FormatInt(taskLogId, 10),
+	}
+	if taskModel.WorkflowRunId > 0 {
+		context["GOCRON_WORKFLOW_RUN_ID"] = strconv.FormatInt(taskModel.WorkflowRunId, 10)
+	}
+	for key, value := range taskModel.RunContext {
+		context[key] = value
+	}
+
+	return context
+}
+
+// 解析任务环境变量, 每行一个 KEY=VALUE, 忽略空行和#开头的注释行
+func ParseTaskEnv(env string) (map[string]string, error) {
+	result := make(map[string]string)
+	for i, line := range strings.Split(env, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pos := strings.Index(line, "=")
+		if pos <= 0 || !envNamePattern.MatchString(line[:pos]) {
+			return nil, fmt.Errorf("第%d行环境变量格式错误, 应为 KEY=VALUE", i+1)
+		}
+		result[line[:pos]] = line[pos+1:]
+	}
+
+	return result, nil
+}
+
+// shell任务的环境变量, 执行上下文覆盖任务配置的同名变量
+func taskEnv(taskModel models.Task, taskLogId int64) map[string]string {
+	env, err := ParseTaskEnv(taskModel.Env)
+	if err != nil {
+		env = make(map[string]string)
+	}
+	for key, value := range runContext(taskModel, taskLogId) {
+		env[key] = value
+	}
+
+	return env
+}
+
+// 上游任务的执行结果
+// GOCRON_PARENT_* 为触发执行的上游任务, GOCRON_UPSTREAM_<任务ID>_* 为所有已执行完成的上游任务
+func (run *workflowRun) upstreamContext(taskId, parentId int) map[string]string {
+	context := make(map[string]string)
+	if result, ok := run.results[parentId]; ok {
+		run.setUpstreamContext(context, "GOCRON_PARENT_", parentId, result)
+	}
+	upstreamIds := make([]string, 0)
+	for _, id := range run.upstream[taskId] {
+		result, ok := run.results[id]
+		if !ok {
+			continue
+		}
+		upstreamIds = append(upstreamIds, strconv.Itoa(id))
+		run.setUpstreamContext(context, fmt.Sprintf("GOCRON_UPSTREAM_%d_", id), id, result)
+	}
+	context["GOCRON_UPSTREAM_TASK_IDS"] = strings.Join(upstreamIds, ",")
+
+	return context
+}
+
+func (run *workflowRun) setUpstreamContext(context map[string]string, prefix string, taskId int, result workflowNodeResult) {
+	status := "success"
+	if result.Err != nil {
+		status = "failure"
+	}
+	// shell任务只传递标准输出, HTTP任务传递响应内容
+	output := result.Result
+	if run.tasks[taskId].Protocol.OnHost() {
+		output = result.Stdout
+	}
+	context[prefix+"TASK_ID"] = strconv.Itoa(taskId)
+	context[prefix+"TASK_LOG_ID"] = strconv.FormatInt(result.taskLogId, 10)
+	context[prefix+"STATUS"] = status
+	context[prefix+"EXIT_CODE"] = strconv.Itoa(result.ExitCode)
+	context[prefix+"OUTPUT"] = truncateOutput(strings.TrimRight(output, "\r\n"), maxRunContextOutput)
+}
+
+// 超出最大长度时保留末尾部分
+func truncateOutput(output string, size int) string {
+	if len(output) <= size {
+		return output
+	}
+	output = output[len(output)-size:]
+	// 避免截断多字节字符
+	for len(output) > 0 && !utf8.RuneStart(output[0]) {
+		output = output[1:]
+	}
+
+	return output
+}
+
+// 替换HTTP任务URL中的模板变量, 变量值经过URL编码
+func replaceRunContext(command string, context map[string]string) string {
+	if !strings.Contains(command, "{{") {
+		return command
+	}
+	replacements := make([]string, 0, len(context)*2)
+	for key, value := range context {
+		replacements = append(replacements, "{{"+key+"}}", url.QueryEscape(value))
+	}
+
+	return strings.NewReplacer(replacements...).Replace(command)
+}
+
+// HTTP任务的请求头, GOCRON_PARENT_TASK_ID 对应 X-Gocron-Parent-Task-Id
+// 输出内容较长且可能包含换行, 不放入请求头, 可通过URL模板变量获取
+func runContextHeader(context map[string]string) http.Header {
+	header := make(http.Header)
+	for key, value := range context {
+		if strings.HasSuffix(key, "_OUTPUT") {
+			continue
+		}
+		name := "X-" + strings.Replace(strings.ToLower(key), "_", "-", -1)
+		header.Set(name, url.QueryEscape(value))
+	}
+
+	return header
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTaskEnv(t *testing.T) {
+	env, err := ParseTaskEnv("# 注释\nAPP_ENV=prod\n\nDSN=user:pass@tcp(127.0.0.1)/db?a=b\nEMPTY=\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"APP_ENV": "prod",
+		"DSN":     "user:pass@tcp(127.0.0.1)/db?a=b",
+		"EMPTY":   "",
+	}
+	if !reflect.DeepEqual(env, expected) {
+		t.Fatalf("环境变量不匹配, 期望%v, 实际%v", expected, env)
+	}
+	for _, item := range []string{"APP_ENV", "=prod", "1APP=prod", "APP-ENV=prod"} {
+		_, err = ParseTaskEnv(item)
+		if err == nil {
+			t.Fatalf("环境变量%q格式错误应返回错误", item)
+		}
+	}
+}
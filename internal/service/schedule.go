@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jakecoffman/cron"
+)
+
+// 预览执行时间的最大次数
+const MaxPreviewCount = 100
+
+// 解析crontab表达式, 按指定时区计算执行时间, 时区为空时使用服务器时区
+func parseSchedule(spec, timezone string) (cron.Schedule, error) {
+	location, err := LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	// @every 按固定间隔执行, 与时区无关
+	if _, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		return schedule, nil
+	}
+
+	return &locationSchedule{schedule: schedule, location: location}, nil
+}
+
+// 解析crontab表达式, 解析失败时cron.Parse会panic, 转换为只包含失败原因的错误
+func parseSpec(spec string) (schedule cron.Schedule, err error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, errors.New("表达式不能为空")
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			schedule = nil
+			err = fmt.Errorf("%v", e)
+		}
+	}()
+
+	return cron.Parse(spec), nil
+}
+
+// 校验crontab表达式, 返回指定时间之后的count次执行时间, 执行时间为时区本地时间
+// 表达式没有可匹配的执行时间(如2月30日)时返回错误
+func NextRunTimes(spec, timezone string, t time.Time, count int) ([]time.Time, error) {
+	schedule, err := parseSchedule(spec, timezone)
+	if err != nil {
+		return nil, err
+	}
+	location, _ := LoadLocation(timezone)
+	times := make([]time.Time, 0, count)
+	for len(times) < count {
+		t = schedule.Next(t)
+		if t.IsZero() {
+			break
+		}
+		times = append(times, t.In(location))
+	}
+	if len(times) == 0 && count > 0 {
+		return nil, errors.New("没有可匹配的执行时间")
+	}
+
+	return times, nil
+}
+
+// 加载时区, 为空时使用服务器时区
+func LoadLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.Local, nil
+	}
+
+	return time.LoadLocation(timezone)
+}
+
+// 按时区本地时间(墙上时间)计算执行时间
+// 夏令时开始时跳过的时间段内的调度, 在时钟跳变时刻执行一次
+// 夏令时结束时重复的时间段内的调度, 只在第一次出现时执行
+type locationSchedule struct {
+	schedule cron.Schedule
+	location *time.Location
+}
+
+func (s *locationSchedule) Next(t time.Time) time.Time {
+	// 墙上时间以UTC表示, 不受夏令时影响
+	wall := toWall(t.In(s.location))
+	// 只有重复、跳过的时间段内的执行时间会早于t, 时区偏移变化不超过一天, 向后查找两天仍未找到时放弃
+	// 按时间范围而不是次数限制, 每秒执行的表达式在重复的一小时内有3600个执行时间
+	limit := wall.Add(48 * time.Hour)
+	for wall.Before(limit) {
+		wall = s.schedule.Next(wall)
+		if wall.IsZero() {
+			return wall
+		}
+		next, ok := fromWall(wall, s.location)
+		if !ok || !next.After(t) {
+			continue
+		}
+		// 与调度器使用相同的时区, 调度器通过 == 比较执行时间
+		return next.In(time.Local)
+	}
+
+	return time.Time{}
+}
+
+// 时刻转换为墙上时间
+func toWall(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// 墙上时间转换为时刻, 重复的墙上时间取第一次出现的时刻, 不存在的墙上时间取时钟跳变的时刻
+func fromWall(wall time.Time, location *time.Location) (time.Time, bool) {
+	// 假定前后一天内最多只有一次时区偏移变化
+	before := zoneOffset(wall.Add(-24*time.Hour), location)
+	after := zoneOffset(wall.Add(24*time.Hour), location)
+	for _, offset := range []int{before, after} {
+		t := wall.Add(-time.Duration(offset) * time.Second)
+		if zoneOffset(t, location) == offset {
+			return t.In(location), true
+		}
+	}
+	if before == after {
+		return time.Time{}, false
+	}
+	// 墙上时间不存在, 二分查找时钟跳变的时刻
+	low := wall.Add(-time.Duration(after) * time.Second)
+	high := wall.Add(-time.Duration(before) * time.Second)
+	if low.After(high) {
+		low, high = high, low
+	}
+	for high.Sub(low) > time.Second {
+		middle := low.Add(high.Sub(low) / 2).Truncate(time.Second)
+		if zoneOffset(middle, location) == after {
+			high = middle
+		} else {
+			low = middle
+		}
+	}
+
+	return high.In(location), true
+}
+
+func zoneOffset(t time.Time, location *time.Location) int {
+	_, offset := t.In(location).Zone()
+
+	return offset
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocationScheduleTimezone(t *testing.T) {
+	schedule, err := parseSchedule("0 0 9 * * *", "Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	next := schedule.Next(now)
+	expected := time.Date(2021, 6, 1, 1, 0, 0, 0, time.UTC)
+	if !next.Equal(expected) {
+		t.Fatalf("执行时间不匹配, 期望%s, 实际%s", expected, next.UTC())
+	}
+}
+
+func TestLocationScheduleDSTStart(t *testing.T) {
+	// 2021-03-14 02:00 纽约夏令时开始, 时钟跳到03:00, 02:30不存在
+	schedule, err := parseSchedule("0 30 2 * * *", "America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2021, 3, 13, 12, 0, 0, 0, time.UTC)
+	next := schedule.Next(now)
+	expected := time.Date(2021, 3, 14, 7, 0, 0, 0, time.UTC)
+	if !next.Equal(expected) {
+		t.Fatalf("跳过的时间应在时钟跳变时执行, 期望%s, 实际%s", expected, next.UTC())
+	}
+	next = schedule.Next(next)
+	expected = time.Date(2021, 3, 15, 6, 30, 0, 0, time.UTC)
+	if !next.Equal(expected) {
+		t.Fatalf("执行时间不匹配, 期望%s, 实际%s", expected, next.UTC())
+	}
+}
+
+func TestLocationScheduleDSTEnd(t *testing.T) {
+	// 2021-11-07 02:00 纽约夏令时结束, 时钟回到01:00, 01:30出现两次
+	schedule, err := parseSchedule("0 30 1 * * *", "America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2021, 11, 6, 12, 0, 0, 0, time.UTC)
+	next := schedule.Next(now)
+	expected := time.Date(2021, 11, 7, 5, 30, 0, 0, time.UTC)
+	if !next.Equal(expected) {
+		t.Fatalf("重复的时间应在第一次出现时执行, 期望%s, 实际%s", expected, next.UTC())
+	}
+	next = schedule.Next(next)
+	expected = time.Date(2021, 11, 8, 6, 30, 0, 0, time.UTC)
+	if !next.Equal(expected) {
+		t.Fatalf("重复的时间不应执行两次, 期望%s, 实际%s", expected, next.UTC())
+	}
+}
+
+func TestLocationScheduleDSTEndEverySecond(t *testing.T) {
+	// 每秒执行, 第二次出现的01:00-02:00内的时间均已执行过, 下次在02:00(EST)执行
+	schedule, err := parseSchedule("* * * * * *", "America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := time.Date(2021, 11, 7, 7, 0, 0, 0, time.UTC)
+	for _, now := range []time.Time{
+		time.Date(2021, 11, 7, 5, 59, 59, 0, time.UTC),
+		time.Date(2021, 11, 7, 6, 0, 0, 0, time.UTC),
+		time.Date(2021, 11, 7, 6, 30, 0, 0, time.UTC),
+	} {
+		next := schedule.Next(now)
+		if !next.Equal(expected) {
+			t.Fatalf("%s之后的执行时间不匹配, 期望%s, 实际%s", now, expected, next.UTC())
+		}
+	}
+	next := schedule.Next(expected)
+	if !next.Equal(expected.Add(time.Second)) {
+		t.Fatalf("执行时间不匹配, 期望%s, 实际%s", expected.Add(time.Second), next.UTC())
+	}
+}
+
+func TestLocationScheduleInvalidTimezone(t *testing.T) {
+	_, err := parseSchedule("0 * * * * *", "Invalid/Zone")
+	if err == nil {
+		t.Fatal("无效的时区应返回错误")
+	}
+}
+
+func TestNextRunTimes(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	times, err := NextRunTimes("0 0 9 * * *", "Asia/Shanghai", now, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) != 3 {
+		t.Fatalf("执行时间数量不匹配, 期望3, 实际%d", len(times))
+	}
+	for i, next := range times {
+		if next.Location().String() != "Asia/Shanghai" {
+			t.Fatalf("执行时间应为任务时区本地时间, 实际%s", next.Location())
+		}
+		expected := time.Date(2021, 6, 1+i, 9, 0, 0, 0, next.Location())
+		if !next.Equal(expected) {
+			t.Fatalf("执行时间不匹配, 期望%s, 实际%s", expected, next)
+		}
+	}
+}
+
+func TestNextRunTimesInvalidSpec(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	specs := map[string]string{
+		"":             "表达式不能为空",
+		"0 9 *":        "Expected 5 or 6 fields, found 3: 0 9 *",
+		"0 0 25 * * *": "End of range (25) above maximum (23): 25",
+		"0 0 0 30 2 *": "没有可匹配的执行时间",
+	}
+	for spec, message := range specs {
+		_, err := NextRunTimes(spec, "", now, 1)
+		if err == nil {
+			t.Fatalf("表达式%q应返回错误", spec)
+		}
+		if err.Error() != message {
+			t.Fatalf("表达式%q错误信息不匹配, 期望%s, 实际%s", spec, message, err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/ouqiang/gocron/internal/models"
+)
+
+// 多个主机执行时, 按任务的成功条件判断整体执行结果
+
+// 执行成功的主机数至少为多少时整体执行成功
+func requiredSuccess(policy models.TaskSuccessPolicy, threshold int16, total int) int {
+	switch policy {
+	case models.TaskSuccessAny:
+		return 1
+	case models.TaskSuccessCount:
+		return int(threshold)
+	case models.TaskSuccessPercent:
+		// 向上取整
+		return (total*int(threshold) + 99) / 100
+	default:
+		return total
+	}
+}
+
+// 成功条件的描述
+func successCondition(policy models.TaskSuccessPolicy, threshold int16) string {
+	switch policy {
+	case models.TaskSuccessAny:
+		return "至少1台主机执行成功"
+	case models.TaskSuccessCount:
+		return fmt.Sprintf("至少%d台主机执行成功", threshold)
+	case models.TaskSuccessPercent:
+		return fmt.Sprintf("至少%d%%的主机执行成功", threshold)
+	default:
+		return "所有主机执行成功"
+	}
+}
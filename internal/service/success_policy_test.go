@@ -0,0 +1,30 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ouqiang/gocron/internal/models"
+)
+
+func TestRequiredSuccess(t *testing.T) {
+	tests := []struct {
+		policy    models.TaskSuccessPolicy
+		threshold int16
+		total     int
+		expected  int
+	}{
+		{models.TaskSuccessAll, 0, 3, 3},
+		{models.TaskSuccessAny, 0, 3, 1},
+		{models.TaskSuccessCount, 2, 3, 2},
+		{models.TaskSuccessCount, 5, 3, 5},
+		{models.TaskSuccessPercent, 50, 3, 2},
+		{models.TaskSuccessPercent, 60, 5, 3},
+		{models.TaskSuccessPercent, 100, 4, 4},
+	}
+	for _, test := range tests {
+		required := requiredSuccess(test.policy, test.threshold, test.total)
+		if required != test.expected {
+			t.Errorf("成功条件%d-%d, %d台主机时需要%d台成功, 实际%d", test.policy, test.threshold, test.total, test.expected, required)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -8,8 +9,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/ouqiang/goutil"
-
 	"github.com/jakecoffman/cron"
 	"github.com/ouqiang/gocron/internal/models"
 	"github.com/ouqiang/gocron/internal/modules/app"
@@ -18,6 +17,7 @@ import (
 	"github.com/ouqiang/gocron/internal/modules/notify"
 	rpcClient "github.com/ouqiang/gocron/internal/modules/rpc/client"
 	pb "github.com/ouqiang/gocron/internal/modules/rpc/proto"
+	"github.com/ouqiang/gocron/internal/modules/utils"
 )
 
 var (
@@ -28,6 +28,10 @@ var (
 	// 定时任务调度管理器
 	serviceCron *cron.Cron
 
+	// 调度器是否运行中, 集群模式下仅主节点运行
+	cronRunning bool
+	cronMutex   sync.Mutex
+
 	// 同一任务是否有实例处于运行中
 	runInstance Instance
 
@@ -102,52 +106,312 @@ type TaskResult struct {
 	Result     string
 	Err        error
 	RetryTimes int8
+	Stdout     string // 标准输出
+	Stderr     string // 错误输出
+	ExitCode   int    // 退出码
+	Signal     string // 终止进程的信号
+	PeakMemory int64  // 内存使用峰值(字节)
+	CpuTime    int64  // CPU时间(毫秒)
+	OutputFile string // 完整输出文件, 输出超出最大长度时保存
+	Summary    string // 多个主机执行时的整体执行结果及各主机执行结果
 }
 
 // 初始化任务, 从数据库取出所有任务, 添加到定时任务并运行
 func (task Task) Initialize() {
 	serviceCron = cron.New()
-	serviceCron.Start()
 	concurrencyQueue = ConcurrencyQueue{queue: make(chan struct{}, app.Setting.ConcurrencyQueue)}
 	taskCount = TaskCount{sync.WaitGroup{}, make(chan struct{})}
 	go taskCount.Wait()
 
 	logger.Info("开始初始化定时任务")
+	if app.Setting.Cluster.Enable {
+		// 记录加载任务前的变更位置, 加载期间产生的变更由同步处理
+		err := ServiceCluster.markTaskChange()
+		if err != nil {
+			logger.Fatalf("定时任务初始化#获取任务变更记录错误: %s", err)
+		}
+	}
+	taskNum, err := task.loadActiveTasks()
+	if err != nil {
+		logger.Fatalf("定时任务初始化#获取任务列表错误: %s", err)
+	}
+	logger.Infof("定时任务初始化完成, 共%d个定时任务添加到调度器", taskNum)
+	if app.Setting.Cluster.Enable {
+		// 集群模式下成为主节点后才开始调度, 重启前未执行完成的任务由主节点接管
+		ServiceCluster.Start()
+		return
+	}
+	// 调度器重启前未执行完成的任务标记为中断
+	interruptedTaskIds := task.markInterrupted()
+	task.recoverMisfired()
+	startScheduler()
+	task.rerunInterrupted(interruptedTaskIds)
+}
+
+// 未执行完成的任务标记为中断, 返回被中断的任务ID
+func (task Task) markInterrupted() []int {
+	taskLogs, err := new(models.TaskLog).UnfinishedList()
+	if err != nil {
+		logger.Error("获取未执行完成的任务日志失败", err)
+		return nil
+	}
+	_, err = new(models.WorkflowRun).MarkInterrupted()
+	if err != nil {
+		logger.Error("未执行完成的工作流标记为中断失败", err)
+	}
+
+	return task.interruptTaskLogs(taskLogs, "调度器重启, 任务执行中断")
+}
+
+// 主节点接管已退出实例(心跳过期、实例标识变化、实例重启)未执行完成的任务, 标记为中断, 返回被中断的任务ID
+// 心跳未过期的实例(包括失去租约的原主节点)继续执行其启动后开始的任务, 不接管
+func (task Task) markOrphanInterrupted() []int {
+	alive, err := new(models.ClusterInstance).AliveList()
+	if err != nil {
+		logger.Error("集群#获取存活实例失败", err)
+		return nil
+	}
+	// 当前实例心跳续约失败时也不能接管自身的任务
+	alive[app.Setting.Cluster.Instance] = instanceStarted
+	taskLogs, err := new(models.TaskLog).OrphanList(alive)
+	if err != nil {
+		logger.Error("集群#获取已退出实例未执行完成的任务日志失败", err)
+		return nil
+	}
+	_, err = new(models.WorkflowRun).MarkOrphanInterrupted(alive)
+	if err != nil {
+		logger.Error("集群#已退出实例未执行完成的工作流标记为中断失败", err)
+	}
+
+	return task.interruptTaskLogs(taskLogs, "调度器实例已退出, 任务执行中断")
+}
+
+func (task Task) interruptTaskLogs(taskLogs []models.TaskLog, reason string) []int {
+	taskLogModel := new(models.TaskLog)
+	taskIds := make([]int, 0)
+	exists := make(map[int]struct{})
+	interrupted := make([]int64, 0, len(taskLogs))
+	for _, item := range taskLogs {
+		result := reason
+		if item.Result != "" {
+			result = item.Result + "\n" + result
+		}
+		// 已执行完成或已被其他实例标记为中断的跳过, 避免重复执行
+		ok, err := taskLogModel.Interrupt(item.Id, result)
+		if err != nil {
+			logger.Errorf("更新任务日志失败#日志ID-%d#%s", item.Id, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		interrupted = append(interrupted, item.Id)
+		// 同一任务多次中断只重新执行一次
+		if _, ok := exists[item.TaskId]; ok {
+			continue
+		}
+		exists[item.TaskId] = struct{}{}
+		taskIds = append(taskIds, item.TaskId)
+	}
+	if len(interrupted) == 0 {
+		return taskIds
+	}
+	logger.Infof("共%d个未执行完成的任务标记为中断", len(interrupted))
+	_, err := new(models.TaskLogHost).MarkInterrupted(interrupted)
+	if err != nil {
+		logger.Error("未执行完成的主机执行记录标记为中断失败", err)
+	}
+
+	return taskIds
+}
+
+// 根据任务配置重新执行被中断的任务
+func (task Task) rerunInterrupted(taskIds []int) {
 	taskModel := new(models.Task)
+	for _, taskId := range taskIds {
+		item, err := taskModel.Detail(taskId)
+		if err != nil || item.Id == 0 {
+			continue
+		}
+		if item.Status != models.Enabled || item.InterruptPolicy != models.TaskInterruptPolicyRerun {
+			continue
+		}
+		item.Spec = "中断后重新执行"
+		task.Run(item)
+	}
+}
+
+// 补执行调度器停止期间错过的调度, 根据最近一次执行时间和crontab表达式计算
+// 没有执行记录的任务无法确定错过的调度, 不补执行
+func (task Task) recoverMisfired() {
+	now := time.Now()
+	taskLogModel := new(models.TaskLog)
+	err := task.walkActiveTasks(func(item models.Task) {
+		if item.MisfirePolicy == models.TaskMisfirePolicySkip {
+			return
+		}
+		lastStartTime, err := taskLogModel.LastStartTime(item.Id)
+		if err != nil {
+			logger.Errorf("补执行#获取任务最近执行时间失败#任务ID-%d#%s", item.Id, err)
+			return
+		}
+		if lastStartTime.IsZero() {
+			return
+		}
+		limit := 1
+		if item.MisfirePolicy == models.TaskMisfirePolicyRunAll {
+			limit = int(item.MisfireLimit)
+		}
+		if limit <= 0 || limit > MaxMisfireLimit {
+			limit = MaxMisfireLimit
+		}
+		times, err := misfireTimes(item.Spec, item.Timezone, lastStartTime, now, limit)
+		if err != nil {
+			logger.Errorf("补执行#计算错过的调度时间失败#任务ID-%d#%s", item.Id, err)
+			return
+		}
+		if len(times) == 0 {
+			return
+		}
+		if createHandler(item) == nil {
+			return
+		}
+		logger.Infof("任务错过调度, 补执行%d次#任务ID-%d", len(times), item.Id)
+		// 按调度时间顺序依次执行
+		go func(item models.Task, times []time.Time) {
+			for _, t := range times {
+				item.Spec = fmt.Sprintf("补执行(%s)", t.Format(models.DefaultTimeFormat))
+				createJob(item)()
+			}
+		}(item, times)
+	})
+	if err != nil {
+		logger.Error("补执行#获取任务列表失败", err)
+	}
+}
+
+// 补执行所有错过的调度时最多执行的次数
+const MaxMisfireLimit = 100
+
+// 计算last之后、now之前错过的调度时间, 最多返回limit个
+func misfireTimes(spec, timezone string, last, now time.Time, limit int) ([]time.Time, error) {
+	schedule, err := parseSchedule(spec, timezone)
+	if err != nil {
+		return nil, err
+	}
+	times := make([]time.Time, 0)
+	for t := schedule.Next(last); !t.IsZero() && t.Before(now) && len(times) < limit; t = schedule.Next(t) {
+		times = append(times, t)
+	}
+
+	return times, nil
+}
+
+// 重新从数据库加载任务到调度器
+func (task Task) Reload() (int, error) {
+	cronMutex.Lock()
+	for _, entry := range serviceCron.Entries() {
+		serviceCron.RemoveJob(entry.Name)
+	}
+	cronMutex.Unlock()
+
+	return task.loadActiveTasks()
+}
+
+// 从数据库取出所有激活的任务添加到调度器
+func (task Task) loadActiveTasks() (int, error) {
 	taskNum := 0
+	err := task.walkActiveTasks(func(item models.Task) {
+		task.Add(item)
+		taskNum++
+	})
+
+	return taskNum, err
+}
+
+// 遍历所有激活的任务
+func (task Task) walkActiveTasks(fn func(models.Task)) error {
+	taskModel := new(models.Task)
 	page := 1
 	pageSize := 1000
 	maxPage := 1000
 	for page < maxPage {
 		taskList, err := taskModel.ActiveList(page, pageSize)
 		if err != nil {
-			logger.Fatalf("定时任务初始化#获取任务列表错误: %s", err)
+			return err
 		}
 		if len(taskList) == 0 {
 			break
 		}
 		for _, item := range taskList {
-			task.Add(item)
-			taskNum++
+			fn(item)
 		}
 		page++
 	}
-	logger.Infof("定时任务初始化完成, 共%d个定时任务添加到调度器", taskNum)
+
+	return nil
+}
+
+// 启动调度器
+func startScheduler() {
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+	if cronRunning {
+		return
+	}
+	serviceCron.Start()
+	cronRunning = true
+}
+
+// 停止调度器, 已在运行的任务不受影响
+func stopScheduler() {
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+	if !cronRunning {
+		return
+	}
+	serviceCron.Stop()
+	cronRunning = false
 }
 
 // 批量添加任务
 func (task Task) BatchAdd(tasks []models.Task) {
-	for _, item := range tasks {
-		task.RemoveAndAdd(item)
+	taskIds := make([]int, len(tasks))
+	for i, item := range tasks {
+		task.removeAndAdd(item)
+		taskIds[i] = item.Id
 	}
+	ServiceCluster.notifyTaskChange(taskIds...)
 }
 
 // 删除任务后添加
 func (task Task) RemoveAndAdd(taskModel models.Task) {
-	task.Remove(taskModel.Id)
+	task.removeAndAdd(taskModel)
+	ServiceCluster.notifyTaskChange(taskModel.Id)
+}
+
+func (task Task) removeAndAdd(taskModel models.Task) {
+	task.remove(taskModel.Id)
 	task.Add(taskModel)
 }
 
+// 从数据库读取任务, 同步调度器
+func (task Task) reconcile(id int) error {
+	taskModel := new(models.Task)
+	item, err := taskModel.Detail(id)
+	if err != nil {
+		return err
+	}
+	// 任务已删除、停用或为子任务
+	if item.Id == 0 || item.Status != models.Enabled || item.Level != models.TaskLevelParent {
+		task.remove(id)
+		return nil
+	}
+	task.removeAndAdd(item)
+
+	return nil
+}
+
 // 添加任务
 func (task Task) Add(taskModel models.Task) {
 	if taskModel.Level == models.TaskLevelChild {
@@ -160,13 +424,15 @@ func (task Task) Add(taskModel models.Task) {
 		return
 	}
 
-	cronName := strconv.Itoa(taskModel.Id)
-	err := goutil.PanicToError(func() {
-		serviceCron.AddFunc(taskModel.Spec, taskFunc, cronName)
-	})
+	schedule, err := parseSchedule(taskModel.Spec, taskModel.Timezone)
 	if err != nil {
 		logger.Error("添加任务到调度器失败#", err)
+		return
 	}
+	cronName := strconv.Itoa(taskModel.Id)
+	cronMutex.Lock()
+	serviceCron.Schedule(schedule, taskFunc, cronName)
+	cronMutex.Unlock()
 }
 
 func (task Task) NextRunTime(taskModel models.Task) time.Time {
@@ -174,29 +440,68 @@ func (task Task) NextRunTime(taskModel models.Task) time.Time {
 		taskModel.Status != models.Enabled {
 		return time.Time{}
 	}
+	cronMutex.Lock()
 	entries := serviceCron.Entries()
+	cronMutex.Unlock()
 	taskName := strconv.Itoa(taskModel.Id)
 	for _, item := range entries {
-		if item.Name == taskName {
-			return item.Next
+		if item.Name != taskName {
+			continue
 		}
+		// 调度器未运行(集群模式下非主节点)时根据表达式计算
+		if item.Next.IsZero() {
+			return item.Schedule.Next(time.Now())
+		}
+		return item.Next
 	}
 
 	return time.Time{}
 }
 
 // 停止运行中的任务
-func (task Task) Stop(ip string, port int, id int64) {
-	rpcClient.Stop(ip, port, id)
+func (task Task) Stop(ip string, port int, id int64) error {
+	return rpcClient.Stop(ip, port, id)
+}
+
+// 执行中的任务正在执行的主机, hostId大于0时只返回该主机
+func (task Task) RunningHosts(taskLogId int64, hostId int16) ([]models.TaskHostDetail, error) {
+	list, err := new(models.TaskLogHost).RunningList(taskLogId)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]models.TaskHostDetail, 0, len(list))
+	for _, item := range list {
+		if hostId > 0 && item.HostId != hostId {
+			continue
+		}
+		hosts = append(hosts, models.TaskHostDetail{
+			TaskHost: models.TaskHost{HostId: item.HostId},
+			Name:     item.Name,
+			Port:     item.Port,
+			Alias:    item.Alias,
+		})
+	}
+
+	return hosts, nil
 }
 
 func (task Task) Remove(id int) {
+	task.remove(id)
+	ServiceCluster.notifyTaskChange(id)
+}
+
+func (task Task) remove(id int) {
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
 	serviceCron.RemoveJob(strconv.Itoa(id))
 }
 
 // 等待所有任务结束后退出
 func (task Task) WaitAndExit() {
-	serviceCron.Stop()
+	if app.Setting.Cluster.Enable {
+		ServiceCluster.Stop()
+	}
+	stopScheduler()
 	taskCount.Exit()
 }
 
@@ -206,7 +511,7 @@ func (task Task) Run(taskModel models.Task) {
 }
 
 type Handler interface {
-	Run(taskModel models.Task, taskUniqueId int64) (string, error)
+	Run(taskModel models.Task, taskUniqueId int64) TaskResult
 }
 
 // HTTP任务
@@ -215,13 +520,16 @@ type HTTPHandler struct{}
 // http任务执行时间不超过300秒
 const HttpExecTimeout = 300
 
-func (h *HTTPHandler) Run(taskModel models.Task, taskUniqueId int64) (result string, err error) {
+func (h *HTTPHandler) Run(taskModel models.Task, taskUniqueId int64) TaskResult {
 	if taskModel.Timeout <= 0 || taskModel.Timeout > HttpExecTimeout {
 		taskModel.Timeout = HttpExecTimeout
 	}
+	context := runContext(taskModel, taskUniqueId)
+	taskModel.Command = replaceRunContext(taskModel.Command, context)
+	header := runContextHeader(context)
 	var resp httpclient.ResponseWrapper
 	if taskModel.HttpMethod == models.TaskHTTPMethodGet {
-		resp = httpclient.Get(taskModel.Command, taskModel.Timeout)
+		resp = httpclient.Get(taskModel.Command, taskModel.Timeout, header)
 	} else {
 		urlFields := strings.Split(taskModel.Command, "?")
 		taskModel.Command = urlFields[0]
@@ -229,50 +537,223 @@ func (h *HTTPHandler) Run(taskModel models.Task, taskUniqueId int64) (result str
 		if len(urlFields) >= 2 {
 			params = urlFields[1]
 		}
-		resp = httpclient.PostParams(taskModel.Command, params, taskModel.Timeout)
+		resp = httpclient.PostParams(taskModel.Command, params, taskModel.Timeout, header)
 	}
 	// 返回状态码非200，均为失败
 	if resp.StatusCode != http.StatusOK {
-		return resp.Body, fmt.Errorf("HTTP状态码非200-->%d", resp.StatusCode)
+		return TaskResult{Result: resp.Body, Err: fmt.Errorf("HTTP状态码非200-->%d", resp.StatusCode)}
 	}
 
-	return resp.Body, err
+	return TaskResult{Result: resp.Body}
 }
 
 // RPC调用执行任务
 type RPCHandler struct{}
 
-func (h *RPCHandler) Run(taskModel models.Task, taskUniqueId int64) (result string, err error) {
+func (h *RPCHandler) Run(taskModel models.Task, taskUniqueId int64) TaskResult {
 	taskRequest := new(pb.TaskRequest)
 	taskRequest.Timeout = int32(taskModel.Timeout)
 	taskRequest.Command = taskModel.Command
 	taskRequest.Id = taskUniqueId
-	resultChan := make(chan TaskResult, len(taskModel.Hosts))
-	for _, taskHost := range taskModel.Hosts {
-		go func(th models.TaskHostDetail) {
-			output, err := rpcClient.Exec(th.Name, th.Port, taskRequest)
-			errorMessage := ""
-			if err != nil {
-				errorMessage = err.Error()
-			}
-			outputMessage := fmt.Sprintf("主机: [%s-%s:%d]\n%s\n%s\n\n",
-				th.Alias, th.Name, th.Port, errorMessage, output,
-			)
-			resultChan <- TaskResult{Err: err, Result: outputMessage}
-		}(taskHost)
-	}
-
-	var aggregationErr error = nil
-	aggregationResult := ""
-	for i := 0; i < len(taskModel.Hosts); i++ {
-		taskResult := <-resultChan
-		aggregationResult += taskResult.Result
+	taskRequest.Env = taskEnv(taskModel, taskUniqueId)
+	taskRequest.Shell = taskModel.Shell
+	taskRequest.WorkDir = taskModel.WorkDir
+	taskRequest.User = taskModel.RunAsUser
+	taskRequest.Group = taskModel.RunAsGroup
+	taskRequest.CpuLimit = int32(taskModel.CpuLimit)
+	taskRequest.MemoryLimit = int32(taskModel.MemoryLimit)
+	taskRequest.PidsLimit = int32(taskModel.PidsLimit)
+	taskRequest.StopSignal = taskModel.StopSignal
+	taskRequest.StopGrace = int32(taskModel.StopGrace)
+	if taskModel.Protocol == models.TaskScript {
+		taskRequest.Script = taskModel.Script
+		taskRequest.Interpreter = taskModel.Interpreter
+	}
+	hosts, err := resolveTaskHosts(taskModel)
+	if err != nil {
+		return TaskResult{Err: err, Result: err.Error()}
+	}
+	taskOutput, stopWatch := watchTaskOutput(taskUniqueId)
+	defer stopWatch()
+	var taskResult TaskResult
+	if taskModel.DispatchStrategy == models.TaskDispatchAll {
+		taskResult = h.runAll(taskModel, hosts, taskRequest, taskOutput)
+	} else {
+		taskResult = h.runOne(dispatchHosts(taskModel, hosts), taskRequest, taskOutput)
+	}
+	taskResult.OutputFile = taskOutput.SaveFile()
+
+	return taskResult
+}
+
+// 所有主机同时执行, 按成功条件判断整体执行结果
+func (h *RPCHandler) runAll(taskModel models.Task, hosts []models.TaskHostDetail, taskRequest *pb.TaskRequest, taskOutput *TaskOutput) TaskResult {
+	recordTaskHosts(taskRequest.Id, hosts)
+	results := make([]TaskResult, len(hosts))
+	var wg sync.WaitGroup
+	for i, taskHost := range hosts {
+		wg.Add(1)
+		go func(i int, th models.TaskHostDetail) {
+			defer wg.Done()
+			results[i] = h.runOnHost(th, taskRequest, taskOutput, len(hosts) > 1)
+		}(i, taskHost)
+	}
+	wg.Wait()
+
+	aggregation := TaskResult{}
+	succeeded := 0
+	var lastErr error
+	breakdown := ""
+	for i, taskResult := range results {
+		aggregation.Result += taskResult.Result
+		aggregation.Stdout += taskResult.Stdout
+		aggregation.Stderr += taskResult.Stderr
+		aggregation.CpuTime += taskResult.CpuTime
+		if taskResult.PeakMemory > aggregation.PeakMemory {
+			aggregation.PeakMemory = taskResult.PeakMemory
+		}
 		if taskResult.Err != nil {
-			aggregationErr = taskResult.Err
+			lastErr = taskResult.Err
+			aggregation.ExitCode = taskResult.ExitCode
+			aggregation.Signal = taskResult.Signal
+			breakdown += fmt.Sprintf("%s: 失败, %s\n", taskHostname(hosts[i]), taskResult.Err)
+		} else {
+			succeeded++
+			breakdown += fmt.Sprintf("%s: 成功\n", taskHostname(hosts[i]))
+		}
+	}
+	condition := successCondition(taskModel.SuccessPolicy, taskModel.SuccessThreshold)
+	verdict := "成功"
+	if succeeded < requiredSuccess(taskModel.SuccessPolicy, taskModel.SuccessThreshold, len(hosts)) {
+		verdict = "失败"
+		aggregation.Err = lastErr
+		if lastErr == nil || taskModel.SuccessPolicy != models.TaskSuccessAll {
+			aggregation.Err = fmt.Errorf("%d/%d台主机执行成功, 未满足成功条件: %s", succeeded, len(hosts), condition)
+		}
+	} else {
+		aggregation.ExitCode = 0
+		aggregation.Signal = ""
+	}
+	// 多个主机时记录整体执行结果及各主机执行结果
+	if len(hosts) > 1 {
+		aggregation.Summary = fmt.Sprintf("执行结果: %s, %d/%d台主机执行成功, 成功条件: %s\n%s",
+			verdict, succeeded, len(hosts), condition, breakdown)
+		aggregation.Result = aggregation.Summary + "\n" + aggregation.Result
+	}
+
+	return aggregation
+}
+
+// 在一个主机执行, 有多个候选主机时(故障转移), 无法连接任务节点或任务节点繁忙则使用下一个主机
+func (h *RPCHandler) runOne(hosts []models.TaskHostDetail, taskRequest *pb.TaskRequest, taskOutput *TaskOutput) TaskResult {
+	var taskResult TaskResult
+	unavailable := ""
+	for i, th := range hosts {
+		recordTaskHosts(taskRequest.Id, []models.TaskHostDetail{th})
+		taskResult = h.runOnHost(th, taskRequest, taskOutput, false)
+		notExecuted := rpcClient.IsUnavailable(taskResult.Err) || rpcClient.IsNodeBusy(taskResult.Err)
+		if !notExecuted || i == len(hosts)-1 {
+			break
 		}
+		logger.Warnf("%s, 使用下一个主机执行#任务日志ID-%d#%s:%d", taskResult.Err, taskRequest.Id, th.Name, th.Port)
+		unavailable += taskResult.Result
+	}
+	taskResult.Result = unavailable + taskResult.Result
+
+	return taskResult
+}
+
+// 在指定主机执行, multiple为true时按主机分别记录输出
+func (h *RPCHandler) runOnHost(th models.TaskHostDetail, taskRequest *pb.TaskRequest, taskOutput *TaskOutput, multiple bool) TaskResult {
+	hostRunning.add(th.HostId)
+	defer hostRunning.done(th.HostId)
+	hostname := taskHostname(th)
+	logHostId := createTaskLogHost(taskRequest.Id, th)
+	resp, err := rpcClient.ExecStream(th.Name, th.Port, taskRequest, app.Setting.Output.MaxSize, func(output string) {
+		taskOutput.Append(hostname, output)
+	})
+	if resp == nil {
+		resp = new(pb.TaskResponse)
+	}
+	errorMessage := ""
+	if err != nil {
+		errorMessage = err.Error()
+	}
+	outputMessage := fmt.Sprintf("主机: [%s]\n%s\n%s\n\n",
+		hostname, errorMessage, resp.Output,
+	)
+	taskResult := TaskResult{
+		Err:        err,
+		Result:     outputMessage,
+		Stdout:     resp.Stdout,
+		Stderr:     resp.Stderr,
+		ExitCode:   int(resp.ExitCode),
+		Signal:     resp.Signal,
+		PeakMemory: resp.PeakMemory,
+		CpuTime:    resp.CpuTime,
+	}
+	if multiple {
+		taskResult.Stdout = fmt.Sprintf("主机: [%s]\n%s\n\n", hostname, resp.Stdout)
+		taskResult.Stderr = fmt.Sprintf("主机: [%s]\n%s\n\n", hostname, resp.Stderr)
+	}
+	finishTaskLogHost(logHostId, resp, err)
+
+	return taskResult
+}
+
+// 创建主机执行记录, 失败时返回0
+func createTaskLogHost(taskLogId int64, th models.TaskHostDetail) int64 {
+	logHost := &models.TaskLogHost{
+		TaskLogId: taskLogId,
+		HostId:    th.HostId,
+		Alias:     th.Alias,
+		Name:      th.Name,
+		Port:      th.Port,
+		Status:    models.Running,
+		StartTime: time.Now(),
+	}
+	id, err := logHost.Create()
+	if err != nil {
+		logger.Error("任务开始执行#写入主机执行记录失败-", err)
+	}
+
+	return id
+}
+
+// 更新主机执行记录
+func finishTaskLogHost(id int64, resp *pb.TaskResponse, err error) {
+	if id == 0 {
+		return
+	}
+	status := models.Finish
+	errorMessage := ""
+	if err != nil {
+		status = models.Failure
+		errorMessage = err.Error()
+	}
+	_, err = new(models.TaskLogHost).Update(id, models.CommonMap{
+		"status":    status,
+		"end_time":  time.Now().Format(models.DefaultTimeFormat),
+		"output":    utils.TruncateOutput(resp.Output, app.Setting.Output.MaxSize),
+		"error":     errorMessage,
+		"exit_code": resp.ExitCode,
+		"signal":    resp.Signal,
+	})
+	if err != nil {
+		logger.Error("任务结束#更新主机执行记录失败-", err)
 	}
+}
+
+func taskHostname(th models.TaskHostDetail) string {
+	return fmt.Sprintf("%s-%s:%d", th.Alias, th.Name, th.Port)
+}
 
-	return aggregationResult, aggregationErr
+// 任务日志中记录实际执行的主机
+func recordTaskHosts(taskLogId int64, hosts []models.TaskHostDetail) {
+	_, err := new(models.TaskLog).Update(taskLogId, models.CommonMap{"hostname": formatTaskHosts(hosts)})
+	if err != nil {
+		logger.Error("任务开始执行#更新任务日志主机失败-", err)
+	}
 }
 
 // 创建任务日志
@@ -284,15 +765,16 @@ func createTaskLog(taskModel models.Task, status models.Status) (int64, error) {
 	taskLogModel.Protocol = taskModel.Protocol
 	taskLogModel.Command = taskModel.Command
 	taskLogModel.Timeout = taskModel.Timeout
-	if taskModel.Protocol == models.TaskRPC {
-		aggregationHost := ""
-		for _, host := range taskModel.Hosts {
-			aggregationHost += fmt.Sprintf("%s - %s<br>", host.Alias, host.Name)
-		}
-		taskLogModel.Hostname = aggregationHost
+	if taskModel.Protocol.OnHost() {
+		taskLogModel.Hostname = formatTaskHosts(taskModel.Hosts)
+	}
+	if taskModel.Protocol == models.TaskScript {
+		taskLogModel.ScriptHash = utils.Sha256(taskModel.Script)
 	}
 	taskLogModel.StartTime = time.Now()
 	taskLogModel.Status = status
+	taskLogModel.Instance = app.Setting.Cluster.Instance
+	taskLogModel.WorkflowRunId = taskModel.WorkflowRunId
 	insertId, err := taskLogModel.Create()
 
 	return insertId, err
@@ -308,10 +790,19 @@ func updateTaskLog(taskLogId int64, taskResult TaskResult) (int64, error) {
 	} else {
 		status = models.Finish
 	}
+	// 多个主机的输出合并后可能超出最大长度
+	maxSize := app.Setting.Output.MaxSize
 	return taskLogModel.Update(taskLogId, models.CommonMap{
 		"retry_times": taskResult.RetryTimes,
 		"status":      status,
-		"result":      result,
+		"result":      utils.TruncateOutput(result, maxSize),
+		"stdout":      utils.TruncateOutput(taskResult.Stdout, maxSize),
+		"stderr":      utils.TruncateOutput(taskResult.Stderr, maxSize),
+		"output_file": taskResult.OutputFile,
+		"exit_code":   taskResult.ExitCode,
+		"signal":      taskResult.Signal,
+		"peak_memory": taskResult.PeakMemory,
+		"cpu_time":    taskResult.CpuTime,
 	})
 
 }
@@ -325,8 +816,12 @@ func createJob(taskModel models.Task) cron.FuncJob {
 		taskCount.Add()
 		defer taskCount.Done()
 
-		taskLogId := beforeExecJob(taskModel)
+		// 每次执行使用任务的副本, 记录本次执行所属的工作流
+		taskModel := taskModel
+		taskLogId := beforeExecJob(&taskModel)
 		if taskLogId <= 0 {
+			// 工作流中的任务未执行视为执行失败
+			execDependencyTask(taskModel, TaskResult{Err: errors.New("任务未执行")}, 0)
 			return
 		}
 
@@ -338,6 +833,18 @@ func createJob(taskModel models.Task) cron.FuncJob {
 		concurrencyQueue.Add()
 		defer concurrencyQueue.Done()
 
+		// 排队期间可能已被标记为中断, 未执行时通知工作流, 避免工作流一直处于执行中
+		claimed, err := new(models.TaskLog).Claim(taskLogId)
+		if err != nil {
+			logger.Error("任务开始执行#更新任务日志失败-", err)
+			execDependencyTask(taskModel, TaskResult{Err: err}, taskLogId)
+			return
+		}
+		if !claimed {
+			execDependencyTask(taskModel, TaskResult{Err: errors.New("任务排队期间执行中断")}, taskLogId)
+			return
+		}
+
 		logger.Infof("开始执行任务#%s#命令-%s", taskModel.Name, taskModel.Command)
 		taskResult := execJob(handler, taskModel, taskLogId)
 		logger.Infof("任务完成#%s#命令-%s", taskModel.Name, taskModel.Command)
@@ -352,7 +859,7 @@ func createHandler(taskModel models.Task) Handler {
 	switch taskModel.Protocol {
 	case models.TaskHTTP:
 		handler = new(HTTPHandler)
-	case models.TaskRPC:
+	case models.TaskRPC, models.TaskScript:
 		handler = new(RPCHandler)
 	}
 
@@ -360,12 +867,20 @@ func createHandler(taskModel models.Task) Handler {
 }
 
 // 任务前置操作
-func beforeExecJob(taskModel models.Task) (taskLogId int64) {
+func beforeExecJob(taskModel *models.Task) (taskLogId int64) {
 	if taskModel.Multi == 0 && runInstance.has(taskModel.Id) {
-		createTaskLog(taskModel, models.Cancel)
+		createTaskLog(*taskModel, models.Cancel)
 		return
 	}
-	taskLogId, err := createTaskLog(taskModel, models.Running)
+	// 有子任务的任务开始一次新的工作流
+	if taskModel.WorkflowRunId == 0 && strings.TrimSpace(taskModel.DependencyTaskId) != "" {
+		workflowRunId, err := startWorkflow(*taskModel)
+		if err != nil {
+			logger.Errorf("任务开始执行#创建工作流失败, 不执行子任务#任务ID-%d#%s", taskModel.Id, err)
+		}
+		taskModel.WorkflowRunId = workflowRunId
+	}
+	taskLogId, err := createTaskLog(*taskModel, models.Queued)
 	if err != nil {
 		logger.Error("任务开始执行#写入任务日志失败-", err)
 		return
@@ -385,42 +900,7 @@ func afterExecJob(taskModel models.Task, taskResult TaskResult, taskLogId int64)
 	// 发送邮件
 	go SendNotification(taskModel, taskResult)
 	// 执行依赖任务
-	go execDependencyTask(taskModel, taskResult)
-}
-
-// 执行依赖任务, 多个任务并发执行
-func execDependencyTask(taskModel models.Task, taskResult TaskResult) {
-	// 父任务才能执行子任务
-	if taskModel.Level != models.TaskLevelParent {
-		return
-	}
-
-	// 是否存在子任务
-	dependencyTaskId := strings.TrimSpace(taskModel.DependencyTaskId)
-	if dependencyTaskId == "" {
-		return
-	}
-
-	// 父子任务关系为强依赖, 父任务执行失败, 不执行依赖任务
-	if taskModel.DependencyStatus == models.TaskDependencyStatusStrong && taskResult.Err != nil {
-		logger.Infof("父子任务为强依赖关系, 父任务执行失败, 不运行依赖任务#主任务ID-%d", taskModel.Id)
-		return
-	}
-
-	// 获取子任务
-	model := new(models.Task)
-	tasks, err := model.GetDependencyTaskList(dependencyTaskId)
-	if err != nil {
-		logger.Errorf("获取依赖任务失败#主任务ID-%d#%s", taskModel.Id, err.Error())
-		return
-	}
-	if len(tasks) == 0 {
-		logger.Errorf("依赖任务列表为空#主任务ID-%d", taskModel.Id)
-	}
-	for _, task := range tasks {
-		task.Spec = fmt.Sprintf("依赖任务(主任务ID-%d)", taskModel.Id)
-		ServiceTask.Run(task)
-	}
+	go execDependencyTask(taskModel, taskResult, taskLogId)
 }
 
 // 发送任务结果通知
@@ -456,7 +936,12 @@ func SendNotification(taskModel models.Task, taskResult TaskResult) {
 		"output":           taskResult.Result,
 		"status":           statusName,
 		"task_id":          taskModel.Id,
-		"remark":  			taskModel.Remark,
+		"remark":           taskModel.Remark,
+		"stdout":           taskResult.Stdout,
+		"stderr":           taskResult.Stderr,
+		"exit_code":        taskResult.ExitCode,
+		"signal":           taskResult.Signal,
+		"summary":          taskResult.Summary,
 	}
 	notify.Push(msg)
 }
@@ -474,16 +959,16 @@ func execJob(handler Handler, taskModel models.Task, taskUniqueId int64) TaskRes
 		execTimes += taskModel.RetryTimes
 	}
 	var i int8 = 0
-	var output string
-	var err error
+	var taskResult TaskResult
 	for i < execTimes {
-		output, err = handler.Run(taskModel, taskUniqueId)
-		if err == nil {
-			return TaskResult{Result: output, Err: err, RetryTimes: i}
+		taskResult = handler.Run(taskModel, taskUniqueId)
+		if taskResult.Err == nil {
+			taskResult.RetryTimes = i
+			return taskResult
 		}
 		i++
 		if i < execTimes {
-			logger.Warnf("任务执行失败#任务id-%d#重试第%d次#输出-%s#错误-%s", taskModel.Id, i, output, err.Error())
+			logger.Warnf("任务执行失败#任务id-%d#重试第%d次#输出-%s#错误-%s", taskModel.Id, i, taskResult.Result, taskResult.Err.Error())
 			if taskModel.RetryInterval > 0 {
 				time.Sleep(time.Duration(taskModel.RetryInterval) * time.Second)
 			} else {
@@ -493,5 +978,7 @@ func execJob(handler Handler, taskModel models.Task, taskUniqueId int64) TaskRes
 		}
 	}
 
-	return TaskResult{Result: output, Err: err, RetryTimes: taskModel.RetryTimes}
+	taskResult.RetryTimes = taskModel.RetryTimes
+
+	return taskResult
 }
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ouqiang/gocron/internal/models"
+	"github.com/ouqiang/gocron/internal/modules/app"
+	"github.com/ouqiang/gocron/internal/modules/logger"
+	"github.com/ouqiang/gocron/internal/modules/utils"
+)
+
+// 运行中任务的输出定时写入任务日志
+const taskOutputFlushInterval = 5 * time.Second
+
+// 运行中任务的实时输出, 任务日志ID作为Key
+var taskOutputs sync.Map
+
+// 任务实时输出, 按主机分别记录
+type TaskOutput struct {
+	mu        sync.RWMutex
+	taskLogId int64
+	hosts     []string
+	outputs   map[string]*utils.CappedBuffer
+	spills    map[string]*outputSpill
+	changed   bool
+	// 按到达顺序追加的输出流, 主机切换时插入主机标识, 只保留末尾部分, 供tail按偏移量增量获取
+	stream      []byte
+	streamStart int64 // stream在输出流中的偏移量
+	streamHost  string
+}
+
+func newTaskOutput(taskLogId int64) *TaskOutput {
+	return &TaskOutput{
+		taskLogId: taskLogId,
+		outputs:   make(map[string]*utils.CappedBuffer),
+		spills:    make(map[string]*outputSpill),
+	}
+}
+
+// 追加主机输出
+func (o *TaskOutput) Append(host string, output string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	buffer, ok := o.outputs[host]
+	if !ok {
+		buffer = &utils.CappedBuffer{MaxSize: app.Setting.Output.MaxSize}
+		o.outputs[host] = buffer
+		o.hosts = append(o.hosts, host)
+	}
+	o.spill(host, buffer, output)
+	buffer.Write([]byte(output))
+	o.appendStream(host, output)
+	o.changed = true
+}
+
+func (o *TaskOutput) appendStream(host string, output string) {
+	if host != o.streamHost {
+		header := fmt.Sprintf("主机: [%s]\n", host)
+		if o.streamHost != "" {
+			header = "\n" + header
+		}
+		o.stream = append(o.stream, header...)
+		o.streamHost = host
+	}
+	o.stream = append(o.stream, output...)
+	maxSize := app.Setting.Output.MaxSize
+	if maxSize <= 0 || len(o.stream) <= maxSize {
+		return
+	}
+	// 丢弃开头部分, 保留的输出从完整字符开始
+	drop := len(o.stream) - maxSize
+	for drop < len(o.stream) && !utf8.RuneStart(o.stream[drop]) {
+		drop++
+	}
+	o.stream = append([]byte(nil), o.stream[drop:]...)
+	o.streamStart += int64(drop)
+}
+
+// 偏移量之后的输出, 返回输出及下次获取的偏移量, 末尾不完整的字符下次返回
+// 偏移量之前的输出已丢弃或偏移量无效时, 从保留的最早输出开始返回, reset为true
+func (o *TaskOutput) Tail(offset int64) (output string, next int64, reset bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	end := o.streamStart + int64(len(o.stream))
+	if offset < o.streamStart || offset > end {
+		offset = o.streamStart
+		reset = true
+	}
+	chunk := o.stream[offset-o.streamStart:]
+	chunk = chunk[:completeRunes(chunk)]
+
+	return string(chunk), offset + int64(len(chunk)), reset
+}
+
+// 末尾完整字符的长度
+func completeRunes(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return i
+			}
+			break
+		}
+	}
+
+	return len(p)
+}
+
+func (o *TaskOutput) String() string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	var result strings.Builder
+	for _, host := range o.hosts {
+		result.WriteString(fmt.Sprintf("主机: [%s]\n%s\n\n", host, o.outputs[host].String()))
+	}
+
+	return result.String()
+}
+
+// 获取运行中任务偏移量之后的实时输出, 任务不在当前实例运行时running为false
+func (task Task) Tail(taskLogId int64, offset int64) (output string, next int64, reset bool, running bool) {
+	value, ok := taskOutputs.Load(taskLogId)
+	if !ok {
+		return "", 0, false, false
+	}
+	output, next, reset = value.(*TaskOutput).Tail(offset)
+
+	return output, next, reset, true
+}
+
+// 记录任务实时输出, 返回的函数用于结束记录
+func watchTaskOutput(taskLogId int64) (*TaskOutput, func()) {
+	output := newTaskOutput(taskLogId)
+	taskOutputs.Store(taskLogId, output)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(taskOutputFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				flushTaskOutput(taskLogId, output)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return output, func() {
+		close(done)
+		<-finished
+		taskOutputs.Delete(taskLogId)
+	}
+}
+
+// 输出写入任务日志, 运行中即可在日志中查看
+func flushTaskOutput(taskLogId int64, output *TaskOutput) {
+	output.mu.Lock()
+	changed := output.changed
+	output.changed = false
+	output.mu.Unlock()
+	if !changed {
+		return
+	}
+	taskLogModel := new(models.TaskLog)
+	_, err := taskLogModel.Update(taskLogId, models.CommonMap{
+		"result": output.String(),
+	})
+	if err != nil {
+		logger.Error("任务运行中#写入实时输出失败-", err)
+	}
+}
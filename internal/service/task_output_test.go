@@ -0,0 +1,92 @@
+package service
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ouqiang/gocron/internal/modules/app"
+	"github.com/ouqiang/gocron/internal/modules/setting"
+)
+
+func TestTaskOutputTail(t *testing.T) {
+	app.Setting = &setting.Setting{}
+	output := newTaskOutput(1)
+	output.Append("a", "1\n")
+	chunk, offset, _ := output.Tail(0)
+	output.Append("b", "2\n")
+	output.Append("a", "中")
+	next, offset, reset := output.Tail(offset)
+	expected := "\n主机: [b]\n2\n\n主机: [a]\n中"
+	if chunk != "主机: [a]\n1\n" || next != expected || reset {
+		t.Fatalf("多主机输出应只追加, 实际%q %q", chunk, next)
+	}
+
+	// 不完整的字符下次返回
+	output.Append("a", "文"[:1])
+	chunk, offset, _ = output.Tail(offset)
+	if chunk != "" {
+		t.Fatalf("不应返回不完整的字符, 实际%q", chunk)
+	}
+	output.Append("a", "文"[1:])
+	chunk, offset, _ = output.Tail(offset)
+	if chunk != "文" {
+		t.Fatalf("期望%q, 实际%q", "文", chunk)
+	}
+
+	// 开头部分被丢弃后从保留的输出开始返回
+	app.Setting.Output.MaxSize = 4
+	output.Append("a", "字符")
+	chunk, _, reset = output.Tail(0)
+	if chunk != "符" || !reset {
+		t.Fatalf("期望从%q开始返回, 实际%q", "符", chunk)
+	}
+	if _, next, _ := output.Tail(offset + 100); next != offset+6 {
+		t.Fatalf("无效的偏移量应重新获取, 实际%d", next)
+	}
+}
+
+func TestTaskOutputSaveFileRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocron-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	app.LogDir = dir
+	app.Setting = &setting.Setting{}
+	app.Setting.Output.MaxSize = 4
+	app.Setting.Output.Spill = true
+
+	output := newTaskOutput(1)
+	output.Append("a", "first")
+	name := output.SaveFile()
+	if name == "" {
+		t.Fatal("输出超出最大长度时应保存文件")
+	}
+	// 重试执行的输出未超出最大长度时仍追加到之前的文件
+	output = newTaskOutput(1)
+	output.Append("a", "2nd")
+	if next := output.SaveFile(); next != name {
+		t.Fatalf("重试执行应返回同一文件, 实际%q", next)
+	}
+
+	file, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "主机: [a]\nfirst\n\n...... 重试执行 ......\n\n主机: [a]\n2nd\n\n"
+	if string(content) != expected {
+		t.Fatalf("期望%q, 实际%q", expected, content)
+	}
+}
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ouqiang/gocron/internal/models"
+	"github.com/ouqiang/gocron/internal/modules/app"
+	"github.com/ouqiang/gocron/internal/modules/logger"
+)
+
+// 工作流, 任务通过子任务ID组成有向无环图, 子任务也可以配置子任务, 一个任务可以是多个任务的子任务
+// 有子任务的任务执行时创建工作流执行记录, 所有下游任务执行完成或被跳过后工作流结束
+// 下游任务的执行条件由上游任务的依赖关系(强依赖、弱依赖)和下游任务的执行条件(所有、任一上游任务)决定
+
+// 执行中的工作流, 工作流执行记录ID作为Key
+var workflowRuns sync.Map
+
+type workflowNodeStatus int8
+
+const (
+	workflowNodePending workflowNodeStatus = iota // 等待上游任务
+	workflowNodeRunning                           // 执行中
+	workflowNodeSuccess                           // 执行成功
+	workflowNodeFailure                           // 执行失败
+	workflowNodeSkipped                           // 不满足执行条件, 跳过
+)
+
+// 工作流中任务的执行结果
+type workflowNodeResult struct {
+	TaskResult
+	taskLogId int64
+}
+
+type workflowRun struct {
+	id         int64
+	rootId     int
+	tasks      map[int]models.Task
+	upstream   map[int][]int
+	downstream map[int][]int
+	status     map[int]workflowNodeStatus
+	results    map[int]workflowNodeResult
+	// 未结束(执行完成或跳过)的任务数
+	remaining int
+	mutex     sync.Mutex
+}
+
+// 从起始任务开始加载工作流中的所有任务, loadTasks根据子任务ID获取任务
+func newWorkflowRun(root models.Task, loadTasks func(ids string) ([]models.Task, error)) (*workflowRun, error) {
+	run := &workflowRun{
+		rootId:     root.Id,
+		tasks:      map[int]models.Task{root.Id: root},
+		upstream:   make(map[int][]int),
+		downstream: make(map[int][]int),
+		status:     map[int]workflowNodeStatus{root.Id: workflowNodeRunning},
+		results:    make(map[int]workflowNodeResult),
+	}
+	queue := []models.Task{root}
+	for len(queue) > 0 {
+		item := queue[0]
+		queue = queue[1:]
+		children, err := loadTasks(strings.TrimSpace(item.DependencyTaskId))
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			run.downstream[item.Id] = append(run.downstream[item.Id], child.Id)
+			run.upstream[child.Id] = append(run.upstream[child.Id], item.Id)
+			if _, ok := run.tasks[child.Id]; ok {
+				continue
+			}
+			run.tasks[child.Id] = child
+			run.status[child.Id] = workflowNodePending
+			queue = append(queue, child)
+		}
+	}
+	cycle := findDependencyCycle(run.downstream)
+	if len(cycle) > 0 {
+		return nil, fmt.Errorf("存在循环依赖: %s", formatDependencyCycle(cycle))
+	}
+	run.remaining = len(run.tasks)
+
+	return run, nil
+}
+
+// 任务执行完成, 返回满足执行条件的下游任务, 以及工作流是否结束
+func (run *workflowRun) finish(taskId int, taskLogId int64, taskResult TaskResult) ([]models.Task, bool) {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	if run.status[taskId] != workflowNodeRunning {
+		return nil, false
+	}
+	if taskResult.Err != nil {
+		run.status[taskId] = workflowNodeFailure
+	} else {
+		run.status[taskId] = workflowNodeSuccess
+	}
+	run.results[taskId] = workflowNodeResult{taskResult, taskLogId}
+	run.remaining--
+	ready := run.propagate(taskId)
+
+	return ready, run.remaining == 0
+}
+
+// 检查下游任务的执行条件, 不满足执行条件的任务跳过, 并继续检查其下游任务
+func (run *workflowRun) propagate(taskId int) []models.Task {
+	ready := make([]models.Task, 0)
+	for _, id := range run.downstream[taskId] {
+		if run.status[id] != workflowNodePending {
+			continue
+		}
+		switch run.evaluate(id) {
+		case workflowNodeRunning:
+			run.status[id] = workflowNodeRunning
+			item := run.tasks[id]
+			item.RunContext = run.upstreamContext(id, taskId)
+			ready = append(ready, item)
+		case workflowNodeSkipped:
+			run.status[id] = workflowNodeSkipped
+			run.remaining--
+			ready = append(ready, run.propagate(id)...)
+		}
+	}
+
+	return ready
+}
+
+// 根据上游任务的执行结果判断任务是否可以执行
+// 强依赖的上游任务执行成功、弱依赖的上游任务执行结束视为满足条件, 上游任务被跳过视为不满足条件
+func (run *workflowRun) evaluate(taskId int) workflowNodeStatus {
+	satisfied, blocked := 0, 0
+	upstream := run.upstream[taskId]
+	for _, id := range upstream {
+		switch run.status[id] {
+		case workflowNodeSuccess:
+			satisfied++
+		case workflowNodeFailure:
+			if run.tasks[id].DependencyStatus == models.TaskDependencyStatusWeak {
+				satisfied++
+			} else {
+				blocked++
+			}
+		case workflowNodeSkipped:
+			blocked++
+		}
+	}
+	if run.tasks[taskId].DependencyTrigger == models.TaskDependencyTriggerAny {
+		if satisfied > 0 {
+			return workflowNodeRunning
+		}
+		if blocked == len(upstream) {
+			return workflowNodeSkipped
+		}
+		return workflowNodePending
+	}
+	if blocked > 0 {
+		return workflowNodeSkipped
+	}
+	if satisfied == len(upstream) {
+		return workflowNodeRunning
+	}
+
+	return workflowNodePending
+}
+
+// 工作流最终状态, 所有任务执行成功为执行完毕, 否则为执行失败
+func (run *workflowRun) result() models.Status {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	for _, status := range run.status {
+		if status != workflowNodeSuccess {
+			return models.Failure
+		}
+	}
+
+	return models.Finish
+}
+
+// 创建工作流执行记录
+func startWorkflow(root models.Task) (int64, error) {
+	run, err := newWorkflowRun(root, new(models.Task).GetDependencyTaskList)
+	if err != nil {
+		return 0, err
+	}
+	record := new(models.WorkflowRun)
+	record.TaskId = root.Id
+	record.Name = root.Name
+	record.Spec = root.Spec
+	record.TaskNum = len(run.tasks)
+	record.Status = models.Running
+	record.Instance = app.Setting.Cluster.Instance
+	run.id, err = record.Create()
+	if err != nil {
+		return 0, err
+	}
+	workflowRuns.Store(run.id, run)
+
+	return run.id, nil
+}
+
+// 执行依赖任务, 多个任务并发执行, 上游任务的执行结果传递给依赖任务
+func execDependencyTask(taskModel models.Task, taskResult TaskResult, taskLogId int64) {
+	if taskModel.WorkflowRunId <= 0 {
+		return
+	}
+	value, ok := workflowRuns.Load(taskModel.WorkflowRunId)
+	if !ok {
+		return
+	}
+	run := value.(*workflowRun)
+	ready, finished := run.finish(taskModel.Id, taskLogId, taskResult)
+	for _, item := range ready {
+		item.WorkflowRunId = run.id
+		item.Spec = fmt.Sprintf("依赖任务(上游任务ID-%d)", taskModel.Id)
+		job := createJob(item)
+		if job == nil {
+			execDependencyTask(item, TaskResult{Err: errors.New("不支持的任务协议")}, 0)
+			continue
+		}
+		go job()
+	}
+	if finished {
+		endWorkflow(run)
+	}
+}
+
+// 工作流结束, 更新执行记录
+func endWorkflow(run *workflowRun) {
+	workflowRuns.Delete(run.id)
+	status := run.result()
+	_, err := new(models.WorkflowRun).Update(run.id, models.CommonMap{
+		"status":   status,
+		"end_time": time.Now().Format(models.DefaultTimeFormat),
+	})
+	if err != nil {
+		logger.Errorf("工作流结束#更新执行记录失败#ID-%d#%s", run.id, err)
+	}
+	logger.Infof("工作流执行完成#ID-%d#起始任务ID-%d", run.id, run.rootId)
+}
+
+// 检测修改任务的子任务后是否产生循环依赖
+func CheckDependencyCycle(taskId int, dependencyTaskIds []int) error {
+	list, err := new(models.Task).DependencyList()
+	if err != nil {
+		return err
+	}
+	edges := make(map[int][]int)
+	for _, item := range list {
+		ids, err := ParseDependencyTaskId(item.DependencyTaskId)
+		if err != nil {
+			continue
+		}
+		edges[item.Id] = ids
+	}
+	edges[taskId] = dependencyTaskIds
+	cycle := findDependencyCycle(edges)
+	if len(cycle) > 0 {
+		return fmt.Errorf("存在循环依赖: %s", formatDependencyCycle(cycle))
+	}
+
+	return nil
+}
+
+// 解析逗号分隔的子任务ID
+func ParseDependencyTaskId(dependencyTaskId string) ([]int, error) {
+	ids := make([]int, 0)
+	for _, value := range strings.Split(dependencyTaskId, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("子任务ID无效: %s", value)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// 查找有向图中的环, 返回环上的任务ID, 首尾相同, 不存在环时返回nil
+func findDependencyCycle(edges map[int][]int) []int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[int]int)
+	path := make([]int, 0)
+	var visit func(id int) []int
+	visit = func(id int) []int {
+		state[id] = visiting
+		path = append(path, id)
+		for _, next := range edges[id] {
+			switch state[next] {
+			case visiting:
+				for i, item := range path {
+					if item == next {
+						cycle := append([]int{}, path[i:]...)
+						return append(cycle, next)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = visited
+
+		return nil
+	}
+	// 按ID顺序遍历, 结果稳定
+	ids := make([]int, 0, len(edges))
+	for id := range edges {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if state[id] != unvisited {
+			continue
+		}
+		if cycle := visit(id); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
+func formatDependencyCycle(cycle []int) string {
+	items := make([]string, len(cycle))
+	for i, id := range cycle {
+		items[i] = strconv.Itoa(id)
+	}
+
+	return strings.Join(items, " -> ")
+}
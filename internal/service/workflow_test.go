@@ -0,0 +1,194 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ouqiang/gocron/internal/models"
+)
+
+func TestFindDependencyCycle(t *testing.T) {
+	edges := map[int][]int{1: {2, 3}, 2: {4}, 3: {4}}
+	if cycle := findDependencyCycle(edges); cycle != nil {
+		t.Fatalf("不应存在循环依赖, 实际%v", cycle)
+	}
+	edges[4] = []int{2}
+	cycle := findDependencyCycle(edges)
+	expected := []int{2, 4, 2}
+	if !reflect.DeepEqual(cycle, expected) {
+		t.Fatalf("循环依赖不匹配, 期望%v, 实际%v", expected, cycle)
+	}
+}
+
+// 根据子任务ID从tasks中加载任务
+func workflowTaskLoader(tasks map[int]models.Task) func(ids string) ([]models.Task, error) {
+	return func(ids string) ([]models.Task, error) {
+		list := make([]models.Task, 0)
+		if ids == "" {
+			return list, nil
+		}
+		for _, value := range strings.Split(ids, ",") {
+			id, _ := strconv.Atoi(value)
+			list = append(list, tasks[id])
+		}
+		return list, nil
+	}
+}
+
+func readyTaskIds(tasks []models.Task) []int {
+	ids := make([]int, len(tasks))
+	for i, item := range tasks {
+		ids[i] = item.Id
+	}
+	sort.Ints(ids)
+
+	return ids
+}
+
+// 1 -> 2, 1 -> 3, 2 -> 4, 3 -> 4
+func newDiamondWorkflow(t *testing.T, trigger models.TaskDependencyTrigger, status models.TaskDependencyStatus) *workflowRun {
+	tasks := map[int]models.Task{
+		1: {Id: 1, DependencyTaskId: "2,3"},
+		2: {Id: 2, DependencyTaskId: "4", DependencyStatus: status},
+		3: {Id: 3, DependencyTaskId: "4", DependencyStatus: status},
+		4: {Id: 4, DependencyTrigger: trigger},
+	}
+	run, err := newWorkflowRun(tasks[1], workflowTaskLoader(tasks))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return run
+}
+
+func TestWorkflowRunTriggerAll(t *testing.T) {
+	run := newDiamondWorkflow(t, models.TaskDependencyTriggerAll, models.TaskDependencyStatusStrong)
+	ready, finished := run.finish(1, 0, TaskResult{})
+	if ids := readyTaskIds(ready); !reflect.DeepEqual(ids, []int{2, 3}) || finished {
+		t.Fatalf("应执行任务2、3, 实际%v", ids)
+	}
+	ready, _ = run.finish(2, 0, TaskResult{})
+	if len(ready) != 0 {
+		t.Fatalf("任务3未完成, 任务4不应执行")
+	}
+	ready, _ = run.finish(3, 0, TaskResult{})
+	if ids := readyTaskIds(ready); !reflect.DeepEqual(ids, []int{4}) {
+		t.Fatalf("应执行任务4, 实际%v", ids)
+	}
+	_, finished = run.finish(4, 0, TaskResult{})
+	if !finished || run.result() != models.Finish {
+		t.Fatal("所有任务执行成功, 工作流应执行完毕")
+	}
+}
+
+func TestWorkflowRunTriggerAllStrongFailure(t *testing.T) {
+	run := newDiamondWorkflow(t, models.TaskDependencyTriggerAll, models.TaskDependencyStatusStrong)
+	run.finish(1, 0, TaskResult{})
+	ready, finished := run.finish(2, 0, TaskResult{Err: errors.New("failure")})
+	if len(ready) != 0 || finished {
+		t.Fatal("强依赖的上游任务失败, 任务4应跳过")
+	}
+	if run.status[4] != workflowNodeSkipped {
+		t.Fatalf("任务4应跳过, 实际状态%d", run.status[4])
+	}
+	_, finished = run.finish(3, 0, TaskResult{})
+	if !finished || run.result() != models.Failure {
+		t.Fatal("存在失败的任务, 工作流应执行失败")
+	}
+}
+
+func TestWorkflowRunTriggerAllWeakFailure(t *testing.T) {
+	run := newDiamondWorkflow(t, models.TaskDependencyTriggerAll, models.TaskDependencyStatusWeak)
+	run.finish(1, 0, TaskResult{})
+	run.finish(2, 0, TaskResult{Err: errors.New("failure")})
+	ready, _ := run.finish(3, 0, TaskResult{})
+	if ids := readyTaskIds(ready); !reflect.DeepEqual(ids, []int{4}) {
+		t.Fatalf("弱依赖的上游任务失败, 任务4仍应执行, 实际%v", ids)
+	}
+}
+
+func TestWorkflowRunTriggerAny(t *testing.T) {
+	run := newDiamondWorkflow(t, models.TaskDependencyTriggerAny, models.TaskDependencyStatusStrong)
+	run.finish(1, 0, TaskResult{})
+	ready, _ := run.finish(2, 0, TaskResult{Err: errors.New("failure")})
+	if len(ready) != 0 {
+		t.Fatal("任务3未完成, 任务4不应执行")
+	}
+	ready, _ = run.finish(3, 0, TaskResult{})
+	if ids := readyTaskIds(ready); !reflect.DeepEqual(ids, []int{4}) {
+		t.Fatalf("任一上游任务成功, 任务4应执行, 实际%v", ids)
+	}
+	ready, _ = run.finish(3, 0, TaskResult{})
+	if len(ready) != 0 {
+		t.Fatal("任务4只应执行一次")
+	}
+}
+
+func TestWorkflowRunSkipPropagation(t *testing.T) {
+	// 1 -> 2 -> 3, 1 -> 3
+	tasks := map[int]models.Task{
+		1: {Id: 1, DependencyTaskId: "2,3"},
+		2: {Id: 2, DependencyTaskId: "3"},
+		3: {Id: 3},
+	}
+	run, err := newWorkflowRun(tasks[1], workflowTaskLoader(tasks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ready, finished := run.finish(1, 0, TaskResult{Err: errors.New("failure")})
+	if len(ready) != 0 || !finished {
+		t.Fatal("起始任务失败, 下游任务应全部跳过, 工作流结束")
+	}
+}
+
+func TestWorkflowRunCycle(t *testing.T) {
+	tasks := map[int]models.Task{
+		1: {Id: 1, DependencyTaskId: "2"},
+		2: {Id: 2, DependencyTaskId: "3"},
+		3: {Id: 3, DependencyTaskId: "2"},
+	}
+	_, err := newWorkflowRun(tasks[1], workflowTaskLoader(tasks))
+	if err == nil {
+		t.Fatal("存在循环依赖时应返回错误")
+	}
+}
+
+func TestWorkflowRunUpstreamContext(t *testing.T) {
+	run := newDiamondWorkflow(t, models.TaskDependencyTriggerAll, models.TaskDependencyStatusWeak)
+	run.finish(1, 10, TaskResult{Result: "ok"})
+	run.finish(2, 11, TaskResult{Result: "/data/import.csv\n"})
+	ready, _ := run.finish(3, 12, TaskResult{Err: errors.New("failure"), ExitCode: 2})
+	if len(ready) != 1 {
+		t.Fatalf("应执行任务4, 实际%v", readyTaskIds(ready))
+	}
+	expected := map[string]string{
+		"GOCRON_PARENT_TASK_ID":         "3",
+		"GOCRON_PARENT_TASK_LOG_ID":     "12",
+		"GOCRON_PARENT_STATUS":          "failure",
+		"GOCRON_PARENT_EXIT_CODE":       "2",
+		"GOCRON_PARENT_OUTPUT":          "",
+		"GOCRON_UPSTREAM_TASK_IDS":      "2,3",
+		"GOCRON_UPSTREAM_2_TASK_ID":     "2",
+		"GOCRON_UPSTREAM_2_OUTPUT":      "/data/import.csv",
+		"GOCRON_UPSTREAM_2_STATUS":      "success",
+		"GOCRON_UPSTREAM_2_TASK_LOG_ID": "11",
+		"GOCRON_UPSTREAM_2_EXIT_CODE":   "0",
+		"GOCRON_UPSTREAM_3_TASK_ID":     "3",
+		"GOCRON_UPSTREAM_3_OUTPUT":      "",
+		"GOCRON_UPSTREAM_3_STATUS":      "failure",
+		"GOCRON_UPSTREAM_3_TASK_LOG_ID": "12",
+		"GOCRON_UPSTREAM_3_EXIT_CODE":   "2",
+	}
+	if !reflect.DeepEqual(ready[0].RunContext, expected) {
+		t.Fatalf("执行上下文不匹配, 期望%v, 实际%v", expected, ready[0].RunContext)
+	}
+
+	command := replaceRunContext("http://127.0.0.1/import?file={{GOCRON_UPSTREAM_2_OUTPUT}}", ready[0].RunContext)
+	if command != "http://127.0.0.1/import?file=%2Fdata%2Fimport.csv" {
+		t.Fatalf("模板变量替换错误, 实际%s", command)
+	}
+}
@@ -37,6 +37,8 @@ SUPPORT_ARCH=(386 amd64)
  
 # 编译参数
 LDFLAGS=''
+# 是否启用cgo, SQLite驱动需要cgo
+ENABLE_CGO=0
 # 需要打包的文件
 INCLUDE_FILE=()
 # 打包文件生成目录
@@ -129,7 +131,23 @@ build() {
             else
                 FILENAME=${BINARY_NAME}
             fi
-            env CGO_ENABLED=0 GOOS=${OS} GOARCH=${ARCH} go build -ldflags "${LDFLAGS}" -o ${BUILD_DIR}/${BINARY_NAME}-${OS}-${ARCH}/${FILENAME} ${MAIN_FILE}
+            # 交叉编译无法启用cgo, 只在本机系统、架构下启用
+            local CGO=0
+            if [[ "${ENABLE_CGO}" = "1" ]];then
+                if [[ "${OS}" = "${GOHOSTOS}" && "${ARCH}" = "${GOHOSTARCH}" ]];then
+                    CGO=1
+                else
+                    print_message "${BINARY_NAME}-${OS}-${ARCH} 交叉编译未启用cgo, 不支持SQLite"
+                fi
+            fi
+            local FLAGS="${LDFLAGS}"
+            local TAGS=""
+            # Linux下静态链接, 不依赖系统C库
+            if [[ "${CGO}" = "1" && "${OS}" = "linux" ]];then
+                FLAGS="${LDFLAGS} -linkmode external -extldflags '-static'"
+                TAGS="netgo osusergo"
+            fi
+            env CGO_ENABLED=${CGO} GOOS=${OS} GOARCH=${ARCH} go build -tags "${TAGS}" -ldflags "${FLAGS}" -o ${BUILD_DIR}/${BINARY_NAME}-${OS}-${ARCH}/${FILENAME} ${MAIN_FILE}
         done
     done
 }
@@ -181,6 +199,7 @@ package_gocron() {
     BINARY_NAME='gocron'
     MAIN_FILE="./cmd/gocron/gocron.go"
     INCLUDE_FILE=()
+    ENABLE_CGO=1
 
 
     run
@@ -190,6 +209,7 @@ package_gocron_node() {
     BINARY_NAME='gocron-node'
     MAIN_FILE="./cmd/node/node.go"
     INCLUDE_FILE=()
+    ENABLE_CGO=0
 
     run
 }
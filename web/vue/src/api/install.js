@@ -6,5 +6,8 @@ export default {
   },
   status (callback) {
     httpClient.get('/install/status', {}, callback)
+  },
+  dbTypes (callback) {
+    httpClient.get('/install/db-types', {}, callback)
   }
 }
@@ -3,5 +3,8 @@ import httpClient from '../utils/httpClient'
 export default {
   loginLogList (query, callback) {
     httpClient.get('/system/login-log', query, callback)
+  },
+  cluster (callback) {
+    httpClient.get('/system/cluster', {}, callback)
   }
 }
@@ -25,6 +25,10 @@ export default {
     ], callback)
   },
 
+  cronPreview (spec, timezone, callback) {
+    httpClient.get('/task/cron/preview', {spec, timezone}, callback)
+  },
+
   update (data, callback) {
     httpClient.post('/task/store', data, callback)
   },
@@ -11,5 +11,17 @@ export default {
 
   stop (id, taskId, callback) {
     httpClient.post('/task/log/stop', {id, task_id: taskId}, callback)
+  },
+
+  stopHost (id, taskId, hostId, callback) {
+    httpClient.post('/task/log/stop', {id, task_id: taskId, host_id: hostId}, callback)
+  },
+
+  host (id, callback) {
+    httpClient.get('/task/log/host', {id}, callback)
+  },
+
+  downloadOutput (id) {
+    httpClient.download('/task/log/output', {id}, `task-log-${id}.log.gz`)
   }
 }
@@ -22,6 +22,7 @@ import NotificationWebhook from '../pages/system/notification/webhook'
 
 import Install from '../pages/install/index'
 import LoginLog from '../pages/system/loginLog'
+import Cluster from '../pages/system/cluster'
 
 Vue.use(Router)
 
@@ -151,6 +152,11 @@ const router = new Router({
       path: '/system/login-log',
       name: 'login-log',
       component: LoginLog
+    },
+    {
+      path: '/system/cluster',
+      name: 'cluster',
+      component: Cluster
     }
   ]
 })
@@ -106,6 +106,26 @@ export default {
     })).catch((error) => failureCallback(error))
   },
 
+  // 下载文件, 返回的不是文件时为JSON格式的错误信息
+  download (uri, params, filename) {
+    axios.get(uri, {params, responseType: 'blob', timeout: 0}).then((res) => {
+      if (res.data.type !== 'application/octet-stream') {
+        const reader = new FileReader()
+        reader.onload = () => {
+          const data = JSON.parse(reader.result)
+          checkResponseCode(data.code, data.message)
+        }
+        reader.readAsText(res.data)
+        return
+      }
+      const link = document.createElement('a')
+      link.href = window.URL.createObjectURL(res.data)
+      link.download = filename
+      link.click()
+      window.URL.revokeObjectURL(link.href)
+    }).catch((error) => failureCallback(error))
+  },
+
   post (uri, data, next) {
     const promise = axios.post(uri, Qs.stringify(data), {
       headers: {